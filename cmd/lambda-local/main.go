@@ -0,0 +1,200 @@
+// Command lambda-local emulates API Gateway, serving the users lambda
+// function over HTTP. Each request is turned into the event API Gateway
+// would send, using the routes in serverless.yml, and handed to the
+// same function lambda.Start gets.
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"time"
+	"unicode/utf8"
+
+	"github.com/EwanValentine/serverless-api-example/pkg/api"
+	"github.com/EwanValentine/serverless-api-example/users/deliveries/lambda/function"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/google/uuid"
+)
+
+// emulator serves a function the way API Gateway would
+type emulator struct {
+	fn        function.Function
+	resources []api.Route
+	timeout   time.Duration
+}
+
+// missingRoute is how API Gateway responds to requests it has no route for
+var missingRoute = []byte(`{"message":"Missing Authentication Token"}`)
+
+// event API Gateway would send for the request made to the resource
+func proxyEvent(r *http.Request, body []byte, resource string, params map[string]string) events.APIGatewayProxyRequest {
+	e := events.APIGatewayProxyRequest{
+		Resource:          resource,
+		Path:              r.URL.Path,
+		HTTPMethod:        r.Method,
+		Headers:           make(map[string]string),
+		MultiValueHeaders: make(map[string][]string),
+		RequestContext: events.APIGatewayProxyRequestContext{
+			RequestID:    uuid.New().String(),
+			Stage:        "local",
+			ResourcePath: resource,
+			HTTPMethod:   r.Method,
+			Path:         r.URL.Path,
+		},
+	}
+
+	for key, values := range r.Header {
+		e.Headers[key] = values[len(values)-1]
+		e.MultiValueHeaders[key] = values
+	}
+
+	// API Gateway sends nulls rather than empty parameters
+	if query := r.URL.Query(); len(query) > 0 {
+		e.QueryStringParameters = make(map[string]string)
+		e.MultiValueQueryStringParameters = make(map[string][]string)
+		for key, values := range query {
+			e.QueryStringParameters[key] = values[len(values)-1]
+			e.MultiValueQueryStringParameters[key] = values
+		}
+	}
+	if len(params) > 0 {
+		e.PathParameters = params
+	}
+
+	if utf8.Valid(body) {
+		e.Body = string(body)
+	} else {
+		e.Body = base64.StdEncoding.EncodeToString(body)
+		e.IsBase64Encoded = true
+	}
+	return e
+}
+
+func (e *emulator) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	route, params, _ := api.Match(e.resources, r.Method, r.URL.Path)
+	if route == nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusForbidden)
+		w.Write(missingRoute)
+		return
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	res, err := e.invoke(proxyEvent(r, body, route.Path, params))
+	if err != nil {
+		// API Gateway hides what went wrong inside the function
+		log.Println("function error:", err)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadGateway)
+		w.Write([]byte(`{"message": "Internal server error"}`))
+		return
+	}
+
+	for key, value := range res.Headers {
+		w.Header().Set(key, value)
+	}
+	for key, values := range res.MultiValueHeaders {
+		for _, value := range values {
+			w.Header().Add(key, value)
+		}
+	}
+
+	out := []byte(res.Body)
+	if res.IsBase64Encoded {
+		if out, err = base64.StdEncoding.DecodeString(res.Body); err != nil {
+			log.Println("function error:", err)
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+	}
+
+	w.WriteHeader(res.StatusCode)
+	w.Write(out)
+}
+
+// invoke the function, with the event and response going through
+// JSON, and the deadline set, as they would on Lambda
+func (e *emulator) invoke(req events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+	payload, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), e.timeout)
+	defer cancel()
+
+	out, err := e.fn(ctx, payload)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(out)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &events.APIGatewayProxyResponse{}
+	if err := json.Unmarshal(data, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// newEmulator for the named function in the config, with its
+// environment applied, unless it's already set locally
+func newEmulator(c *config, name string) (*emulator, error) {
+	fn, ok := c.Functions[name]
+	if !ok {
+		return nil, fmt.Errorf("no function named %q", name)
+	}
+
+	for key, value := range fn.Environment {
+		if _, ok := os.LookupEnv(key); !ok {
+			os.Setenv(key, value)
+		}
+	}
+
+	handler, err := function.New(true)
+	if err != nil {
+		return nil, err
+	}
+
+	return &emulator{
+		fn:        handler,
+		resources: fn.resources(),
+		timeout:   c.timeout(fn),
+	}, nil
+}
+
+func main() {
+	configPath := flag.String("config", "serverless.yml", "serverless config declaring the function's routes")
+	name := flag.String("function", "users", "function to serve")
+	flag.Parse()
+
+	port := os.Getenv("PORT")
+
+	c, err := loadConfig(*configPath)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	e, err := newEmulator(c, *name)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	log.Println("Running on port: ", port)
+	log.Panic(http.ListenAndServe(":"+port, e))
+}
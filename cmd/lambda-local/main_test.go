@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/EwanValentine/serverless-api-example/users"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigDeclaresTheUsersRoutes(t *testing.T) {
+	c, err := loadConfig("../../serverless.yml")
+	assert.NoError(t, err)
+	assert.Equal(t, defaultTimeout, c.timeout(c.Functions["users"]))
+	assert.Equal(t, "720h", c.Functions["users"].Environment["RETENTION"])
+
+	methods := map[string][]string{}
+	for _, route := range c.Functions["users"].resources() {
+		methods[route.Path] = append(methods[route.Path], route.Method)
+	}
+	assert.Equal(t, anyMethod, methods["/users"])
+	assert.Equal(t, anyMethod, methods["/users/{id}"])
+	assert.Equal(t, []string{"POST"}, methods["/users/{id}/restore"])
+	assert.Empty(t, c.Functions["purge"].resources())
+}
+
+func TestEmulatorServesTheFunction(t *testing.T) {
+	os.Setenv("DATASTORE", users.DatastoreMemory)
+	c, err := loadConfig("../../serverless.yml")
+	assert.NoError(t, err)
+	e, err := newEmulator(c, "users")
+	assert.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	body := bytes.NewBufferString(`{ "name": "Test User", "email": "test@test.com", "age": 30 }`)
+	e.ServeHTTP(w, httptest.NewRequest("POST", "/users", body))
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, `"1"`, w.Header().Get("ETag"))
+
+	user := &users.User{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), user))
+
+	w = httptest.NewRecorder()
+	e.ServeHTTP(w, httptest.NewRequest("GET", "/users/"+user.ID, nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// Paths serverless.yml doesn't declare never reach the function
+	w = httptest.NewRecorder()
+	e.ServeHTTP(w, httptest.NewRequest("GET", "/users/"+user.ID+"/unknown", nil))
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
+
+func TestEmulatorAppliesTheTimeout(t *testing.T) {
+	c := &config{}
+	c.Provider.Timeout = 10
+	assert.Equal(t, time.Second*10, c.timeout(functionConfig{}))
+	assert.Equal(t, time.Second*3, c.timeout(functionConfig{Timeout: 3}))
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"github.com/EwanValentine/serverless-api-example/pkg/api"
+	"gopkg.in/yaml.v2"
+)
+
+// defaultTimeout is how long the Serverless Framework gives functions by default
+const defaultTimeout = time.Second * 6
+
+// anyMethod is every method an ANY route accepts
+var anyMethod = []string{"DELETE", "GET", "HEAD", "OPTIONS", "PATCH", "POST", "PUT"}
+
+// config is just enough of serverless.yml to emulate API Gateway
+type config struct {
+	Provider struct {
+		Timeout int `yaml:"timeout"`
+	} `yaml:"provider"`
+	Functions map[string]functionConfig `yaml:"functions"`
+}
+
+type functionConfig struct {
+	Timeout     int               `yaml:"timeout"`
+	Environment map[string]string `yaml:"environment"`
+	Events      []eventConfig     `yaml:"events"`
+}
+
+type eventConfig struct {
+	HTTP *httpEvent `yaml:"http"`
+}
+
+// httpEvent is a REST API endpoint, written either in full or
+// in short, e.g. "GET users/{id}"
+type httpEvent struct {
+	Path   string `yaml:"path"`
+	Method string `yaml:"method"`
+}
+
+// UnmarshalYAML -
+func (e *httpEvent) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var short string
+	if err := unmarshal(&short); err == nil {
+		parts := strings.Fields(short)
+		if len(parts) == 2 {
+			e.Method, e.Path = parts[0], parts[1]
+		}
+		return nil
+	}
+
+	type full httpEvent
+	return unmarshal((*full)(e))
+}
+
+func loadConfig(path string) (*config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &config{}
+	if err := yaml.Unmarshal(data, c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// timeout of the function, as Lambda would enforce it
+func (c *config) timeout(fn functionConfig) time.Duration {
+	switch {
+	case fn.Timeout > 0:
+		return time.Duration(fn.Timeout) * time.Second
+	case c.Provider.Timeout > 0:
+		return time.Duration(c.Provider.Timeout) * time.Second
+	default:
+		return defaultTimeout
+	}
+}
+
+// resources the function's http events declare, as routes, which are only
+// matched to find the resource and path parameters, so have no handler
+func (fn functionConfig) resources() []api.Route {
+	routes := make([]api.Route, 0)
+	for _, e := range fn.Events {
+		if e.HTTP == nil {
+			continue
+		}
+
+		path := "/" + strings.Trim(e.HTTP.Path, "/")
+		methods := []string{strings.ToUpper(e.HTTP.Method)}
+		if methods[0] == "ANY" {
+			methods = anyMethod
+		}
+
+		for _, method := range methods {
+			routes = append(routes, api.Route{Method: method, Path: path})
+		}
+	}
+	return routes
+}
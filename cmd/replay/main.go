@@ -0,0 +1,128 @@
+// Command replay replays API Gateway events, one JSON object per line,
+// through the users lambda handler, and reports where the responses
+// differ from those recorded with them. With -record, the responses
+// are recorded instead, so captured traffic becomes a regression test.
+//
+//	$ go run ./cmd/replay -record captured.jsonl
+//	$ go run ./cmd/replay captured.jsonl
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/EwanValentine/serverless-api-example/pkg/helpers"
+	"github.com/EwanValentine/serverless-api-example/users"
+	"github.com/EwanValentine/serverless-api-example/users/deliveries/rest"
+)
+
+// defaultIgnore are the fields which change from run to run
+const defaultIgnore = "createdAt,updatedAt,deletedAt,requestId"
+
+// run replays the entries read from in, reporting differences to out,
+// and returns the entries with the responses we got, and how many
+// didn't match what was recorded
+func run(ctx context.Context, r *replayer, in io.Reader, out io.Writer) ([]entry, int, error) {
+	entries := make([]entry, 0)
+	mismatches := 0
+
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 64*1024), 10*1024*1024)
+	for n := 1; scanner.Scan(); n++ {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		e, err := parseEntry(line)
+		if err != nil {
+			return nil, 0, fmt.Errorf("line %d: %v", n, err)
+		}
+
+		replayed, diffs, err := r.replay(ctx, e)
+		if err != nil {
+			return nil, 0, fmt.Errorf("line %d: %v", n, err)
+		}
+
+		if len(diffs) > 0 {
+			mismatches++
+			fmt.Fprintf(out, "line %d: %s %s\n", n, e.Event.HTTPMethod, e.Event.Path)
+			for _, diff := range diffs {
+				fmt.Fprintf(out, "  %s\n", strings.Replace(diff, "\n", "\n  ", -1))
+			}
+		}
+
+		entries = append(entries, replayed)
+	}
+	return entries, mismatches, scanner.Err()
+}
+
+// record the entries back to the file, with the responses we got
+func record(path string, entries []entry) error {
+	var buf bytes.Buffer
+	for _, e := range entries {
+		line, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+	return ioutil.WriteFile(path, buf.Bytes(), 0644)
+}
+
+func main() {
+	rerecord := flag.Bool("record", false, "record the responses, rather than comparing them")
+	ignore := flag.String("ignore", defaultIgnore, "comma separated fields and headers to leave out of comparisons")
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: replay [-record] [-ignore fields] events.jsonl")
+		os.Exit(2)
+	}
+	path := flag.Arg(0)
+
+	// Replay against a fresh datastore, unless told otherwise
+	if os.Getenv("DATASTORE") == "" {
+		os.Setenv("DATASTORE", users.DatastoreMemory)
+	}
+
+	usecase, err := users.Init(true)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	r := newReplayer(helpers.Router(rest.Routes(usecase)), strings.Split(*ignore, ","))
+	entries, mismatches, err := run(context.Background(), r, f, os.Stdout)
+	f.Close()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *rerecord {
+		if err := record(path, entries); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("recorded %d responses\n", len(entries))
+		return
+	}
+
+	fmt.Printf("%d of %d responses differ\n", mismatches, len(entries))
+	if mismatches > 0 {
+		os.Exit(1)
+	}
+}
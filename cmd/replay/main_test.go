@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/EwanValentine/serverless-api-example/pkg/helpers"
+	"github.com/EwanValentine/serverless-api-example/users"
+	"github.com/EwanValentine/serverless-api-example/users/deliveries/rest"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestReplayer() *replayer {
+	usecase := &users.Usecase{Repository: users.NewMemoryRepository()}
+	return newReplayer(helpers.Router(rest.Routes(usecase)), strings.Split(defaultIgnore, ","))
+}
+
+func TestRecordingReplaysCleanly(t *testing.T) {
+	f, err := os.Open("testdata/users.jsonl")
+	assert.NoError(t, err)
+	defer f.Close()
+
+	var out bytes.Buffer
+	entries, mismatches, err := run(context.Background(), newTestReplayer(), f, &out)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 7)
+	assert.Equal(t, 0, mismatches, out.String())
+
+	// The user created is found, updated and deleted, by its new ID
+	statuses := make([]int, 0, len(entries))
+	for _, e := range entries {
+		statuses = append(statuses, e.Response.StatusCode)
+	}
+	assert.Equal(t, []int{201, 200, 200, 200, 409, 204, 404}, statuses)
+	assert.NotContains(t, entries[1].Event.Path, "c1479902-5564-4f87-812e-24c275251999")
+}
+
+func TestMismatchesAreReported(t *testing.T) {
+	in := strings.NewReader(`{"event":{"resource":"/users/{id}","path":"/users/abc123","httpMethod":"GET","pathParameters":{"id":"abc123"}},"response":{"statusCode":200,"headers":{"Content-Type":"application/json"},"body":"{}"}}
+{"resource":"/users","path":"/users","httpMethod":"GET"}
+`)
+
+	var out bytes.Buffer
+	entries, mismatches, err := run(context.Background(), newTestReplayer(), in, &out)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, mismatches)
+	assert.Contains(t, out.String(), "line 1: GET /users/abc123")
+	assert.Contains(t, out.String(), "status: expected 200, got 404")
+	assert.Contains(t, out.String(), `header Content-Type: expected "application/json", got "application/problem+json"`)
+
+	// Events without a recorded response are replayed, ready to be recorded
+	assert.Equal(t, 200, entries[1].Response.StatusCode)
+}
+
+func TestLineDiff(t *testing.T) {
+	assert.Equal(t, "  a\n- b\n+ c\n  d\n", lineDiff("a\nb\nd\n", "a\nc\nd\n"))
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/EwanValentine/serverless-api-example/pkg/helpers"
+)
+
+// entry is a line of a replay file, an API Gateway event and the
+// response recorded for it. Captured events without a response yet
+// can be given as they are, rather than wrapped in an entry.
+type entry struct {
+	Event    helpers.Request   `json:"event"`
+	Response *helpers.Response `json:"response,omitempty"`
+}
+
+func parseEntry(line []byte) (entry, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(line, &fields); err != nil {
+		return entry{}, err
+	}
+
+	e := entry{}
+	if _, ok := fields["event"]; ok {
+		err := json.Unmarshal(line, &e)
+		return e, err
+	}
+	err := json.Unmarshal(line, &e.Event)
+	return e, err
+}
+
+// replayer replays events through a handler, comparing the responses
+// with those recorded. IDs are generated afresh on every run, so the IDs
+// in recorded responses are mapped onto those we get back, and swapped
+// into the events which follow.
+type replayer struct {
+	handler func(context.Context, helpers.Request) (helpers.Response, error)
+
+	// ignore are fields, of JSON bodies at any depth, and headers,
+	// which change from run to run, e.g. timestamps
+	ignore map[string]bool
+	ids    map[string]string
+}
+
+func newReplayer(handler func(context.Context, helpers.Request) (helpers.Response, error), ignore []string) *replayer {
+	r := &replayer{
+		handler: handler,
+		ignore:  make(map[string]bool),
+		ids:     make(map[string]string),
+	}
+	for _, field := range ignore {
+		r.ignore[strings.ToLower(field)] = true
+	}
+	return r
+}
+
+// replay the entry's event, returning the entry as replayed, with the
+// response we got, and how that differs from the one recorded, if any
+func (r *replayer) replay(ctx context.Context, e entry) (entry, []string, error) {
+	replayed := entry{Event: r.rewrite(e.Event)}
+	res, err := r.handler(ctx, replayed.Event)
+	if err != nil {
+		return replayed, nil, err
+	}
+	replayed.Response = &res
+
+	if e.Response == nil {
+		return replayed, nil, nil
+	}
+
+	r.learn(*e.Response, res)
+	return replayed, r.diff(*e.Response, res), nil
+}
+
+func (r *replayer) swap(s string) string {
+	for recorded, actual := range r.ids {
+		s = strings.Replace(s, recorded, actual, -1)
+	}
+	return s
+}
+
+// rewrite the event with the IDs learnt so far
+func (r *replayer) rewrite(event helpers.Request) helpers.Request {
+	if len(r.ids) == 0 {
+		return event
+	}
+
+	event.Path = r.swap(event.Path)
+	event.Body = r.swap(event.Body)
+
+	params := make(map[string]string, len(event.PathParameters))
+	for key, value := range event.PathParameters {
+		params[key] = r.swap(value)
+	}
+	event.PathParameters = params
+
+	query := make(map[string]string, len(event.QueryStringParameters))
+	for key, value := range event.QueryStringParameters {
+		query[key] = r.swap(value)
+	}
+	event.QueryStringParameters = query
+	return event
+}
+
+// learn the ID we got back in place of the one recorded
+func (r *replayer) learn(expected, actual helpers.Response) {
+	var recorded, got struct {
+		ID string `json:"id"`
+	}
+	if json.Unmarshal([]byte(expected.Body), &recorded) != nil || json.Unmarshal([]byte(actual.Body), &got) != nil {
+		return
+	}
+
+	if recorded.ID != "" && got.ID != "" && recorded.ID != got.ID {
+		r.ids[recorded.ID] = got.ID
+	}
+}
+
+// diff the responses, ignoring the ignored headers and fields
+func (r *replayer) diff(expected, actual helpers.Response) []string {
+	diffs := make([]string, 0)
+	if expected.StatusCode != actual.StatusCode {
+		diffs = append(diffs, fmt.Sprintf("status: expected %d, got %d", expected.StatusCode, actual.StatusCode))
+	}
+
+	keys := make([]string, 0, len(expected.Headers))
+	for key := range expected.Headers {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if r.ignore[strings.ToLower(key)] {
+			continue
+		}
+		value := helpers.Request{Headers: actual.Headers}.Header(key)
+		if value != r.swap(expected.Headers[key]) {
+			diffs = append(diffs, fmt.Sprintf("header %s: expected %q, got %q", key, expected.Headers[key], value))
+		}
+	}
+
+	expectedBody, actualBody := r.normalize(r.swap(expected.Body)), r.normalize(actual.Body)
+	if expectedBody != actualBody {
+		diffs = append(diffs, "body:\n"+lineDiff(expectedBody, actualBody))
+	}
+	return diffs
+}
+
+// normalize JSON bodies, so they're compared field by field, without
+// the fields which are ignored. Other bodies are compared as they are.
+func (r *replayer) normalize(body string) string {
+	var value interface{}
+	if err := json.Unmarshal([]byte(body), &value); err != nil {
+		return body
+	}
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(r.strip(value))
+	return buf.String()
+}
+
+func (r *replayer) strip(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, field := range v {
+			if r.ignore[strings.ToLower(key)] {
+				delete(v, key)
+				continue
+			}
+			v[key] = r.strip(field)
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = r.strip(item)
+		}
+	}
+	return value
+}
+
+// lineDiff shows the lines removed from a, and added in b, to make b
+func lineDiff(a, b string) string {
+	x, y := strings.Split(strings.TrimRight(a, "\n"), "\n"), strings.Split(strings.TrimRight(b, "\n"), "\n")
+
+	// Longest common subsequence of lines, from the end
+	lcs := make([][]int, len(x)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(y)+1)
+	}
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var out strings.Builder
+	i, j := 0, 0
+	for i < len(x) || j < len(y) {
+		switch {
+		case i < len(x) && j < len(y) && x[i] == y[j]:
+			out.WriteString("  " + x[i] + "\n")
+			i++
+			j++
+		case i < len(x) && (j == len(y) || lcs[i+1][j] >= lcs[i][j+1]):
+			out.WriteString("- " + x[i] + "\n")
+			i++
+		default:
+			out.WriteString("+ " + y[j] + "\n")
+			j++
+		}
+	}
+	return out.String()
+}
@@ -1,20 +1,59 @@
 package main
 
 import (
+	"context"
+	"github.com/EwanValentine/serverless-api-example/pkg/tracing"
 	delivery "github.com/EwanValentine/serverless-api-example/users/deliveries/http"
 	"log"
-	"net/http"
+	"net"
 	"os"
+	"os/signal"
+	"syscall"
 )
 
 func main() {
-	port := os.Getenv("PORT")
+	c, err := configFromEnv(os.Getenv)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	shutdownTracing, err := tracing.Setup(tracing.ConfigFromEnv("users"))
+	if err != nil {
+		log.Panic(err)
+	}
 
 	router, err := delivery.Routes()
 	if err != nil {
 		log.Panic(err)
 	}
 
-	log.Println("Running on port: ", port)
-	log.Panic(http.ListenAndServe(":"+port, router))
+	srv, err := newServer(c, withMetrics(router))
+	if err != nil {
+		log.Panic(err)
+	}
+
+	ln, err := net.Listen("tcp", c.Addr)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	log.Println("Running on: ", ln.Addr(), "tls:", c.tls())
+	err = serve(ctx, srv, ln, c)
+
+	// Spans are exported as they end, this closes the exporter
+	flush, cancel := context.WithTimeout(context.Background(), c.ShutdownTimeout)
+	shutdownTracing(flush)
+	cancel()
+
+	if err == context.DeadlineExceeded {
+		log.Println("Requests still running after", c.ShutdownTimeout, "were cancelled")
+		os.Exit(1)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Println("Shut down")
 }
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// config of the server, see configFromEnv
+type config struct {
+	Addr string
+
+	ReadTimeout       time.Duration
+	ReadHeaderTimeout time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+
+	// ShutdownTimeout is how long in-flight requests get to
+	// finish on shutdown, before they're cancelled
+	ShutdownTimeout time.Duration
+
+	// CertFile and KeyFile serve TLS when they're both given,
+	// ClientCAFile then requires clients to present certificates
+	// signed by one of its CAs.
+	CertFile     string
+	KeyFile      string
+	ClientCAFile string
+}
+
+// The write timeout is longer than the default request timeout,
+// so requests which time out still get their 504.
+var defaults = config{
+	ReadTimeout:       time.Second * 10,
+	ReadHeaderTimeout: time.Second * 5,
+	WriteTimeout:      time.Second * 10,
+	IdleTimeout:       time.Second * 120,
+	ShutdownTimeout:   time.Second * 20,
+}
+
+// configFromEnv reads PORT, the timeouts, e.g. WRITE_TIMEOUT=30s,
+// and TLS_CERT_FILE, TLS_KEY_FILE and TLS_CLIENT_CA_FILE
+func configFromEnv(getenv func(string) string) (config, error) {
+	c := defaults
+	c.Addr = ":" + getenv("PORT")
+	c.CertFile = getenv("TLS_CERT_FILE")
+	c.KeyFile = getenv("TLS_KEY_FILE")
+	c.ClientCAFile = getenv("TLS_CLIENT_CA_FILE")
+
+	for key, timeout := range map[string]*time.Duration{
+		"READ_TIMEOUT":        &c.ReadTimeout,
+		"READ_HEADER_TIMEOUT": &c.ReadHeaderTimeout,
+		"WRITE_TIMEOUT":       &c.WriteTimeout,
+		"IDLE_TIMEOUT":        &c.IdleTimeout,
+		"SHUTDOWN_TIMEOUT":    &c.ShutdownTimeout,
+	} {
+		value := getenv(key)
+		if value == "" {
+			continue
+		}
+
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return config{}, fmt.Errorf("invalid %s: %v", key, err)
+		}
+		*timeout = d
+	}
+
+	if (c.CertFile == "") != (c.KeyFile == "") {
+		return config{}, errors.New("TLS_CERT_FILE and TLS_KEY_FILE must be given together")
+	}
+	if c.ClientCAFile != "" && c.CertFile == "" {
+		return config{}, errors.New("TLS_CLIENT_CA_FILE needs TLS_CERT_FILE and TLS_KEY_FILE")
+	}
+	return c, nil
+}
+
+// tls is whether the server should serve TLS
+func (c config) tls() bool {
+	return c.CertFile != ""
+}
+
+// withMetrics serves Prometheus' metrics on /metrics,
+// alongside the handler, which serves everything else
+func withMetrics(handler http.Handler) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	mux.Handle("/", handler)
+	return mux
+}
+
+// newServer for the handler, as configured
+func newServer(c config, handler http.Handler) (*http.Server, error) {
+	srv := &http.Server{
+		Addr:              c.Addr,
+		Handler:           handler,
+		ReadTimeout:       c.ReadTimeout,
+		ReadHeaderTimeout: c.ReadHeaderTimeout,
+		WriteTimeout:      c.WriteTimeout,
+		IdleTimeout:       c.IdleTimeout,
+	}
+
+	if !c.tls() {
+		return srv, nil
+	}
+
+	srv.TLSConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	if c.ClientCAFile != "" {
+		pem, err := ioutil.ReadFile(c.ClientCAFile)
+		if err != nil {
+			return nil, err
+		}
+
+		cas := x509.NewCertPool()
+		if !cas.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", c.ClientCAFile)
+		}
+		srv.TLSConfig.ClientCAs = cas
+		srv.TLSConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return srv, nil
+}
+
+// serve on the listener until ctx is done, then stop taking new
+// requests and wait up to the ShutdownTimeout for in-flight ones.
+// Those still running after that are cancelled, by closing the server,
+// and context.DeadlineExceeded is returned.
+func serve(ctx context.Context, srv *http.Server, ln net.Listener, c config) error {
+	errs := make(chan error, 1)
+	go func() {
+		if c.tls() {
+			errs <- srv.ServeTLS(ln, c.CertFile, c.KeyFile)
+			return
+		}
+		errs <- srv.Serve(ln)
+	}()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdown, cancel := context.WithTimeout(context.Background(), c.ShutdownTimeout)
+	defer cancel()
+
+	if err := srv.Shutdown(shutdown); err != nil {
+		srv.Close()
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func env(vars map[string]string) func(string) string {
+	return func(key string) string {
+		return vars[key]
+	}
+}
+
+func TestConfigFromEnv(t *testing.T) {
+	c, err := configFromEnv(env(map[string]string{"PORT": "8005", "WRITE_TIMEOUT": "30s"}))
+	assert.NoError(t, err)
+	assert.Equal(t, ":8005", c.Addr)
+	assert.Equal(t, time.Second*30, c.WriteTimeout)
+	assert.Equal(t, defaults.ShutdownTimeout, c.ShutdownTimeout)
+	assert.False(t, c.tls())
+
+	for _, vars := range []map[string]string{
+		{"IDLE_TIMEOUT": "forever"},
+		{"TLS_CERT_FILE": "cert.pem"},
+		{"TLS_CLIENT_CA_FILE": "ca.pem"},
+	} {
+		_, err = configFromEnv(env(vars))
+		assert.Error(t, err, vars)
+	}
+}
+
+// listen on a random port, serving the handler until the test's done
+func listen(t *testing.T, c config, handler http.Handler) (string, context.CancelFunc, chan error) {
+	srv, err := newServer(c, handler)
+	assert.NoError(t, err)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- serve(ctx, srv, ln, c) }()
+	t.Cleanup(cancel)
+
+	return ln.Addr().String(), cancel, done
+}
+
+func TestServeDrainsRequestsOnShutdown(t *testing.T) {
+	started := make(chan struct{})
+	addr, stop, done := listen(t, defaults, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		time.Sleep(time.Millisecond * 100)
+		w.Write([]byte("finished"))
+	}))
+
+	responses := make(chan string, 1)
+	go func() {
+		res, err := http.Get("http://" + addr)
+		assert.NoError(t, err)
+		body, _ := ioutil.ReadAll(res.Body)
+		responses <- string(body)
+	}()
+
+	<-started
+	stop()
+	assert.NoError(t, <-done)
+	assert.Equal(t, "finished", <-responses)
+
+	_, err := http.Get("http://" + addr)
+	assert.Error(t, err)
+}
+
+func TestServeCancelsRequestsAfterTheShutdownTimeout(t *testing.T) {
+	c := defaults
+	c.ShutdownTimeout = time.Millisecond * 50
+
+	started := make(chan struct{})
+	addr, stop, done := listen(t, c, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-r.Context().Done()
+	}))
+
+	go http.Get("http://" + addr)
+	<-started
+	stop()
+	assert.Equal(t, context.DeadlineExceeded, <-done)
+}
+
+// certificate signed by the parent, or self signed without one
+func certificate(t *testing.T, dir, name string, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  parent == nil,
+	}
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	assert.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	assert.NoError(t, err)
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	assert.NoError(t, err)
+	ioutil.WriteFile(filepath.Join(dir, name+".pem"), pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	ioutil.WriteFile(filepath.Join(dir, name+"-key.pem"), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600)
+	return cert, key
+}
+
+func TestServeVerifiesClientCertificates(t *testing.T) {
+	dir := t.TempDir()
+	ca, caKey := certificate(t, dir, "ca", nil, nil)
+	certificate(t, dir, "server", ca, caKey)
+	certificate(t, dir, "client", ca, caKey)
+
+	c, err := configFromEnv(env(map[string]string{
+		"TLS_CERT_FILE":      filepath.Join(dir, "server.pem"),
+		"TLS_KEY_FILE":       filepath.Join(dir, "server-key.pem"),
+		"TLS_CLIENT_CA_FILE": filepath.Join(dir, "ca.pem"),
+	}))
+	assert.NoError(t, err)
+
+	addr, _, _ := listen(t, c, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.TLS.PeerCertificates[0].Subject.CommonName))
+	}))
+
+	cas := x509.NewCertPool()
+	cas.AddCert(ca)
+	client := func(certs ...tls.Certificate) *http.Client {
+		return &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: cas, Certificates: certs}}}
+	}
+
+	_, err = client().Get("https://" + addr)
+	assert.Error(t, err)
+
+	cert, err := tls.LoadX509KeyPair(filepath.Join(dir, "client.pem"), filepath.Join(dir, "client-key.pem"))
+	assert.NoError(t, err)
+	res, err := client(cert).Get("https://" + addr)
+	assert.NoError(t, err)
+	body, _ := ioutil.ReadAll(res.Body)
+	assert.Equal(t, "client", string(body))
+}
+
+func TestMetricsAreServed(t *testing.T) {
+	handler := withMetrics(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("users"))
+	}))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "go_goroutines")
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/users", nil))
+	assert.Equal(t, "users", w.Body.String())
+}
@@ -0,0 +1,104 @@
+// Package api describes HTTP requests and responses independently of the
+// transport they arrive on, so handlers can be written once and served
+// over net/http, API Gateway events, or anything else with an adapter.
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"github.com/EwanValentine/serverless-api-example/pkg/apperrors"
+	"github.com/EwanValentine/serverless-api-example/pkg/logging"
+	"go.uber.org/zap"
+)
+
+// Request is an HTTP request, whichever transport it came in on
+type Request struct {
+	Method string
+	Path   string
+
+	// PathParams are the parameters matched by the route's path, e.g. id for /users/{id}
+	PathParams map[string]string
+	Query      map[string]string
+	Headers    map[string]string
+	Body       []byte
+
+	// RequestID identifies the request in logs and error responses
+	RequestID string
+}
+
+// Header gets a request header, regardless of how the client cased it
+func (r Request) Header(name string) string {
+	for key, value := range r.Headers {
+		if strings.EqualFold(key, name) {
+			return value
+		}
+	}
+	return ""
+}
+
+// Response is an HTTP response, to be written out by the transport
+type Response struct {
+	Status  int
+	Headers map[string]string
+
+	// Cookies are each sent as a Set-Cookie header, which
+	// is the one header responses need more than once
+	Cookies []string
+	Body    []byte
+}
+
+// Handler handles a single request
+type Handler func(ctx context.Context, req Request) Response
+
+// Route is a handler for requests with the method, made to paths
+// matching the pattern, e.g. /users/{id}. Patterns are written the
+// same way as they are in serverless.yml. Operation names what the
+// route does, e.g. create, so it can be configured, see Timeouts.
+type Route struct {
+	Method    string
+	Path      string
+	Operation string
+	Handler   Handler
+}
+
+// Serve the request with the route's handler. Anything it logs
+// says which request it was for, and the route it was routed to.
+func (r *Route) Serve(ctx context.Context, req Request) Response {
+	fields := []zap.Field{zap.String("method", req.Method), zap.String("route", r.Path)}
+	if req.RequestID != "" {
+		fields = append(fields, zap.String("requestId", req.RequestID))
+	}
+
+	return r.Handler(logging.With(ctx, fields...), req)
+}
+
+// JSON responds with data encoded as JSON
+func JSON(status int, data interface{}) Response {
+	body, err := json.Marshal(data)
+	if err != nil {
+		return Fail(Request{}, err)
+	}
+
+	return Response{
+		Status:  status,
+		Headers: map[string]string{"Content-Type": "application/json"},
+		Body:    body,
+	}
+}
+
+// Fail responds with problem details describing err
+func Fail(req Request, err error) Response {
+	problem := apperrors.ProblemOf(err, req.Path, req.RequestID)
+
+	// We don't need to worry about this error,
+	// as we're controlling the input.
+	body, _ := json.Marshal(problem)
+
+	return Response{
+		Status:  problem.Status,
+		Headers: map[string]string{"Content-Type": apperrors.ContentType},
+		Body:    body,
+	}
+}
@@ -0,0 +1,126 @@
+package api
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/EwanValentine/serverless-api-example/pkg/apperrors"
+)
+
+var (
+	// ErrNoRoute is returned for requests to paths we don't have a route for
+	ErrNoRoute = apperrors.New(apperrors.NotFound, "no route matches the request")
+
+	// ErrMethodNotAllowed is returned for requests to paths we only
+	// have routes for with other methods
+	ErrMethodNotAllowed = apperrors.New(apperrors.MethodNotAllowed, "method not allowed")
+)
+
+// Match finds the route for the method and path, and the parameters its
+// pattern matched. Patterns are made of segments, which are either literal,
+// a parameter matching a single segment, e.g. {id}, or, as the last
+// segment, a greedy parameter matching the rest of the path, e.g. {proxy+}.
+// When more than one route matches, the one with the most literal segments
+// wins. If none match, allowed lists the methods routes do have for the path.
+func Match(routes []Route, method, path string) (route *Route, params map[string]string, allowed []string) {
+	best := -1
+	for i := range routes {
+		p, literals, ok := match(routes[i].Path, path)
+		if !ok {
+			continue
+		}
+
+		if routes[i].Method != method {
+			allowed = appendMethod(allowed, routes[i].Method)
+			continue
+		}
+
+		if literals > best {
+			route, params, best = &routes[i], p, literals
+		}
+	}
+
+	if route != nil {
+		return route, params, nil
+	}
+	sort.Strings(allowed)
+	return nil, nil, allowed
+}
+
+func appendMethod(methods []string, method string) []string {
+	for _, m := range methods {
+		if m == method {
+			return methods
+		}
+	}
+	return append(methods, method)
+}
+
+func segments(path string) []string {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return nil
+	}
+	return strings.Split(path, "/")
+}
+
+// match the path against the pattern, counting its literal segments
+func match(pattern, path string) (map[string]string, int, bool) {
+	patternSegments, pathSegments := segments(pattern), segments(path)
+	params := make(map[string]string)
+	literals := 0
+
+	for i, segment := range patternSegments {
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "+}") {
+			if i != len(patternSegments)-1 || i >= len(pathSegments) {
+				return nil, 0, false
+			}
+			params[segment[1:len(segment)-2]] = strings.Join(pathSegments[i:], "/")
+			return params, literals, true
+		}
+
+		if i >= len(pathSegments) {
+			return nil, 0, false
+		}
+
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			params[segment[1:len(segment)-1]] = pathSegments[i]
+			continue
+		}
+
+		if segment != pathSegments[i] {
+			return nil, 0, false
+		}
+		literals++
+	}
+
+	if len(patternSegments) != len(pathSegments) {
+		return nil, 0, false
+	}
+	return params, literals, true
+}
+
+// NoRoute responds to requests which didn't match a route, with
+// a 404, or a 405 and the methods allowed when there are any
+func NoRoute(req Request, allowed []string) Response {
+	if len(allowed) == 0 {
+		return Fail(req, ErrNoRoute)
+	}
+
+	res := Fail(req, ErrMethodNotAllowed)
+	res.Headers["Allow"] = strings.Join(allowed, ", ")
+	return res
+}
+
+// Serve the request with the route matching its method and path,
+// filling in the parameters matched from the path
+func Serve(ctx context.Context, routes []Route, req Request) Response {
+	route, params, allowed := Match(routes, req.Method, req.Path)
+	if route == nil {
+		return NoRoute(req, allowed)
+	}
+
+	req.PathParams = params
+	return route.Serve(ctx, req)
+}
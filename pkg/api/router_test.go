@@ -0,0 +1,57 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func named(name string) Handler {
+	return func(ctx context.Context, req Request) Response {
+		return Response{Status: http.StatusOK, Body: []byte(name + " " + req.PathParams["id"] + req.PathParams["proxy"])}
+	}
+}
+
+var routes = []Route{
+	{Method: "GET", Path: "/users", Handler: named("list")},
+	{Method: "POST", Path: "/users", Handler: named("create")},
+	{Method: "GET", Path: "/users/{id}", Handler: named("get")},
+	{Method: "GET", Path: "/users/me", Handler: named("me")},
+	{Method: "DELETE", Path: "/users/{id}", Handler: named("delete")},
+	{Method: "POST", Path: "/users/{id}/restore", Handler: named("restore")},
+	{Method: "GET", Path: "/files/{proxy+}", Handler: named("file")},
+}
+
+func TestServeMatchesPatterns(t *testing.T) {
+	for path, expected := range map[string]string{
+		"/users":              "list ",
+		"/users/":             "list ",
+		"/users/abc123":       "get abc123",
+		"/users/me":           "me ",
+		"/files/a/b/c.txt":    "file a/b/c.txt",
+		"/users/abc123/other": "",
+		"/files":              "",
+		"/":                   "",
+	} {
+		res := Serve(context.Background(), routes, Request{Method: "GET", Path: path})
+		if expected == "" {
+			assert.Equal(t, http.StatusNotFound, res.Status, path)
+			continue
+		}
+		assert.Equal(t, expected, string(res.Body), path)
+	}
+
+	res := Serve(context.Background(), routes, Request{Method: "POST", Path: "/users/abc123/restore"})
+	assert.Equal(t, "restore abc123", string(res.Body))
+}
+
+func TestServeMethodNotAllowed(t *testing.T) {
+	res := Serve(context.Background(), routes, Request{Method: "PUT", Path: "/users/abc123"})
+	assert.Equal(t, http.StatusMethodNotAllowed, res.Status)
+	assert.Equal(t, "DELETE, GET", res.Headers["Allow"])
+
+	res = Serve(context.Background(), routes, Request{Method: "PATCH", Path: "/users"})
+	assert.Equal(t, "GET, POST", res.Headers["Allow"])
+}
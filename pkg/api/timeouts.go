@@ -0,0 +1,96 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/EwanValentine/serverless-api-example/pkg/apperrors"
+)
+
+// DefaultTimeout is how long operations get, unless they're configured otherwise
+const DefaultTimeout = time.Second * 5
+
+// ErrTimeout is returned for requests which ran out of time
+var ErrTimeout = apperrors.New(apperrors.Timeout, "the request took too long")
+
+// Timeouts are how long each operation gets to handle a request,
+// by the Operation of its route, with a default for the rest.
+type Timeouts struct {
+	Default    time.Duration
+	Operations map[string]time.Duration
+}
+
+// For the operation, falling back to the default, then DefaultTimeout
+func (t Timeouts) For(operation string) time.Duration {
+	if timeout, ok := t.Operations[operation]; ok {
+		return timeout
+	}
+	if t.Default > 0 {
+		return t.Default
+	}
+	return DefaultTimeout
+}
+
+// ParseTimeouts from a comma separated list of operation=duration,
+// e.g. "3s,getAll=10s". A duration on its own sets the default.
+func ParseTimeouts(config string) (Timeouts, error) {
+	timeouts := Timeouts{Operations: make(map[string]time.Duration)}
+	for _, entry := range strings.Split(config, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		operation, value := "", entry
+		if i := strings.Index(entry, "="); i >= 0 {
+			operation, value = strings.TrimSpace(entry[:i]), strings.TrimSpace(entry[i+1:])
+		}
+
+		timeout, err := time.ParseDuration(value)
+		if err != nil || timeout <= 0 {
+			return Timeouts{}, fmt.Errorf("invalid timeout %q", entry)
+		}
+
+		if operation == "" {
+			timeouts.Default = timeout
+			continue
+		}
+		timeouts.Operations[operation] = timeout
+	}
+	return timeouts, nil
+}
+
+// TimeoutsFromEnv parses the TIMEOUTS env var, see ParseTimeouts
+func TimeoutsFromEnv() (Timeouts, error) {
+	return ParseTimeouts(os.Getenv("TIMEOUTS"))
+}
+
+// WithTimeouts gives each route's handler a context which times out
+// after its operation's timeout. Deadlines already on the context, such
+// as lambda's, still apply when they're sooner. Handlers which fail
+// because they ran out of time respond with a 504.
+func WithTimeouts(routes []Route, timeouts Timeouts) []Route {
+	timed := make([]Route, len(routes))
+	for i, route := range routes {
+		route.Handler = withTimeout(route.Handler, timeouts.For(route.Operation))
+		timed[i] = route
+	}
+	return timed
+}
+
+func withTimeout(handler Handler, timeout time.Duration) Handler {
+	return func(ctx context.Context, req Request) Response {
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+
+		res := handler(ctx, req)
+		if res.Status == http.StatusInternalServerError && ctx.Err() == context.DeadlineExceeded {
+			return Fail(req, ErrTimeout)
+		}
+		return res
+	}
+}
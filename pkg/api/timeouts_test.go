@@ -0,0 +1,56 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTimeouts(t *testing.T) {
+	timeouts, err := ParseTimeouts("3s, getAll=10s,create=1s")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Second*3, timeouts.For("get"))
+	assert.Equal(t, time.Second*10, timeouts.For("getAll"))
+	assert.Equal(t, time.Second, timeouts.For("create"))
+
+	timeouts, err = ParseTimeouts("")
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultTimeout, timeouts.For("get"))
+
+	for _, config := range []string{"soon", "get=", "get=-1s"} {
+		_, err = ParseTimeouts(config)
+		assert.Error(t, err, config)
+	}
+}
+
+func TestWithTimeoutsBoundsEachOperation(t *testing.T) {
+	var deadlines []time.Duration
+	measure := func(ctx context.Context, req Request) Response {
+		deadline, _ := ctx.Deadline()
+		deadlines = append(deadlines, time.Until(deadline).Round(time.Second))
+		return Response{Status: http.StatusOK}
+	}
+
+	routes := WithTimeouts([]Route{
+		{Method: "GET", Path: "/users", Operation: "getAll", Handler: measure},
+		{Method: "GET", Path: "/users/{id}", Operation: "get", Handler: measure},
+	}, Timeouts{Default: time.Second * 2, Operations: map[string]time.Duration{"getAll": time.Second * 10}})
+
+	Serve(context.Background(), routes, Request{Method: "GET", Path: "/users"})
+	Serve(context.Background(), routes, Request{Method: "GET", Path: "/users/abc123"})
+	assert.Equal(t, []time.Duration{time.Second * 10, time.Second * 2}, deadlines)
+}
+
+func TestWithTimeoutsReportsRunningOutOfTime(t *testing.T) {
+	slow := func(ctx context.Context, req Request) Response {
+		<-ctx.Done()
+		return Fail(req, ctx.Err())
+	}
+
+	routes := WithTimeouts([]Route{{Method: "GET", Path: "/users", Handler: slow}}, Timeouts{Default: time.Millisecond})
+	res := Serve(context.Background(), routes, Request{Method: "GET", Path: "/users"})
+	assert.Equal(t, http.StatusGatewayTimeout, res.Status)
+}
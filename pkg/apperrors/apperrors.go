@@ -0,0 +1,128 @@
+// Package apperrors describes failures by what kind they are, rather than
+// where they came from, so every delivery reports them the same way.
+package apperrors
+
+import (
+	"net/http"
+)
+
+// Code is the kind of failure, as reported to clients
+type Code string
+
+const (
+	// Validation is for requests which are malformed or break the rules
+	Validation Code = "validation"
+
+	// NotFound is for things which don't exist
+	NotFound Code = "not_found"
+
+	// MethodNotAllowed is for methods a resource doesn't support
+	MethodNotAllowed Code = "method_not_allowed"
+
+	// Conflict is for writes which clash with existing data
+	Conflict Code = "conflict"
+
+	// PreconditionFailed is for writes based on a stale version
+	PreconditionFailed Code = "precondition_failed"
+
+	// Unauthorized is for callers who aren't allowed to do what they asked
+	Unauthorized Code = "unauthorized"
+
+	// Throttled is for requests turned away under load, which can be retried
+	Throttled Code = "throttled"
+
+	// Timeout is for requests which ran out of time before finishing
+	Timeout Code = "timeout"
+
+	// Internal is for everything else, which is our fault
+	Internal Code = "internal"
+)
+
+var statuses = map[Code]int{
+	Validation:         http.StatusBadRequest,
+	NotFound:           http.StatusNotFound,
+	MethodNotAllowed:   http.StatusMethodNotAllowed,
+	Conflict:           http.StatusConflict,
+	PreconditionFailed: http.StatusPreconditionFailed,
+	Unauthorized:       http.StatusUnauthorized,
+	Throttled:          http.StatusTooManyRequests,
+	Timeout:            http.StatusGatewayTimeout,
+	Internal:           http.StatusInternalServerError,
+}
+
+// FieldError describes why a single field is invalid
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// Error is a failure with a code, and a message safe to show clients
+type Error struct {
+	Code    Code
+	Message string
+
+	// Fields which failed validation, if any
+	Fields []FieldError
+
+	// Err is what went wrong underneath, if anything
+	Err error
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+// Unwrap -
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// New error with the given code
+func New(code Code, message string) error {
+	return &Error{Code: code, Message: message}
+}
+
+// Wrap err with the given code
+func Wrap(err error, code Code, message string) error {
+	return &Error{Code: code, Message: message, Err: err}
+}
+
+// As finds the first *Error in err's chain, following both
+// github.com/pkg/errors causes and standard library wrapping.
+func As(err error) (*Error, bool) {
+	for err != nil {
+		if e, ok := err.(*Error); ok {
+			return e, true
+		}
+
+		switch wrapped := err.(type) {
+		case interface{ Cause() error }:
+			err = wrapped.Cause()
+		case interface{ Unwrap() error }:
+			err = wrapped.Unwrap()
+		default:
+			return nil, false
+		}
+	}
+	return nil, false
+}
+
+// CodeOf err, errors without a code are Internal
+func CodeOf(err error) Code {
+	if e, ok := As(err); ok {
+		return e.Code
+	}
+	return Internal
+}
+
+// Status is the HTTP status code for a code
+func Status(code Code) int {
+	if status, ok := statuses[code]; ok {
+		return status
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,17 @@
+package apperrors
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestCodeOfFollowsWrappedErrors(t *testing.T) {
+	notFound := New(NotFound, "user not found")
+
+	assert.Equal(t, NotFound, CodeOf(notFound))
+	assert.Equal(t, NotFound, CodeOf(errors.Wrap(notFound, "error fetching user")))
+	assert.Equal(t, NotFound, CodeOf(fmt.Errorf("error fetching user: %w", notFound)))
+	assert.Equal(t, Internal, CodeOf(errors.New("boom")))
+}
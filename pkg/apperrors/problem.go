@@ -0,0 +1,59 @@
+package apperrors
+
+import (
+	"net/http"
+
+	"github.com/EwanValentine/serverless-api-example/pkg/redact"
+)
+
+// ContentType of problem details, as described by RFC 7807
+const ContentType = "application/problem+json"
+
+// TypeBase prefixes the code of a failure to give its problem type,
+// e.g. /problems/not_found. Internal errors use about:blank instead.
+const TypeBase = "/problems/"
+
+// Problem details of a failed request, as described by RFC 7807,
+// with the failure's code, invalid fields and the request ID as
+// extension members.
+type Problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail,omitempty"`
+	Instance  string       `json:"instance,omitempty"`
+	Code      Code         `json:"code"`
+	Errors    []FieldError `json:"errors,omitempty"`
+	RequestID string       `json:"requestId,omitempty"`
+}
+
+// ProblemOf describes err as problem details, for the request
+// made to instance. Unexpected errors don't give away any
+// details, they're reported as internal server errors.
+// Personal information in 5xx details is redacted.
+func ProblemOf(err error, instance, requestID string) Problem {
+	problem := Problem{
+		Type:      "about:blank",
+		Status:    Status(Internal),
+		Instance:  instance,
+		Code:      Internal,
+		RequestID: requestID,
+	}
+
+	if e, ok := As(err); ok && e.Code != Internal {
+		problem.Type = TypeBase + string(e.Code)
+		problem.Status = Status(e.Code)
+		problem.Detail = e.Message
+		problem.Code = e.Code
+		problem.Errors = e.Fields
+	}
+
+	// Server errors are the ones most likely to echo what
+	// they were given, so their details are redacted
+	if problem.Status >= http.StatusInternalServerError {
+		problem.Detail = redact.String(problem.Detail)
+	}
+
+	problem.Title = http.StatusText(problem.Status)
+	return problem
+}
@@ -0,0 +1,39 @@
+package apperrors
+
+import (
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+)
+
+func TestProblemOf(t *testing.T) {
+	err := errors.Wrap(New(Conflict, "email already in use"), "error creating user")
+	assert.Equal(t, Problem{
+		Type:      "/problems/conflict",
+		Title:     "Conflict",
+		Status:    http.StatusConflict,
+		Detail:    "email already in use",
+		Instance:  "/users",
+		Code:      Conflict,
+		RequestID: "abc123",
+	}, ProblemOf(err, "/users", "abc123"))
+
+	problem := ProblemOf(Wrap(errors.New("timeout"), Throttled, "too many requests"), "/users", "")
+	assert.Equal(t, http.StatusTooManyRequests, problem.Status)
+	assert.Equal(t, "too many requests", problem.Detail)
+
+	// Unexpected errors don't give away what went wrong
+	assert.Equal(t, Problem{
+		Type:     "about:blank",
+		Title:    "Internal Server Error",
+		Status:   http.StatusInternalServerError,
+		Instance: "/users/abc123",
+		Code:     Internal,
+	}, ProblemOf(errors.New("connection refused"), "/users/abc123", ""))
+
+	// Nor does personal information in server errors
+	problem = ProblemOf(New(Timeout, "timed out looking up ewan@test.com"), "/users", "")
+	assert.Equal(t, http.StatusGatewayTimeout, problem.Status)
+	assert.Equal(t, "timed out looking up e***@test.com", problem.Detail)
+}
@@ -0,0 +1,32 @@
+package helpers
+
+import (
+	"strconv"
+	"strings"
+)
+
+// ETag formats a record version as a strong entity tag
+func ETag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+// IfMatch parses an If-Match header into the version it expects.
+// A missing header or "*" expects any version, which is zero. It's
+// false for anything we couldn't have issued, such as weak tags, which
+// can never match, so callers should fail the precondition.
+func IfMatch(header string) (int64, bool) {
+	header = strings.TrimSpace(header)
+	if header == "" || header == "*" {
+		return 0, true
+	}
+
+	if len(header) < 2 || header[0] != '"' || header[len(header)-1] != '"' {
+		return 0, false
+	}
+
+	version, err := strconv.ParseInt(header[1:len(header)-1], 10, 64)
+	if err != nil || version < 1 {
+		return 0, false
+	}
+	return version, true
+}
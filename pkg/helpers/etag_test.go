@@ -0,0 +1,28 @@
+package helpers
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestIfMatch(t *testing.T) {
+	cases := []struct {
+		header  string
+		version int64
+		ok      bool
+	}{
+		{"", 0, true},
+		{"*", 0, true},
+		{ETag(3), 3, true},
+		{`W/"3"`, 0, false},
+		{"3", 0, false},
+		{`"abc"`, 0, false},
+		{`"1", "2"`, 0, false},
+	}
+
+	for _, c := range cases {
+		version, ok := IfMatch(c.header)
+		assert.Equal(t, c.version, version, c.header)
+		assert.Equal(t, c.ok, ok, c.header)
+	}
+}
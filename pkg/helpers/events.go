@@ -0,0 +1,176 @@
+package helpers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/EwanValentine/serverless-api-example/pkg/api"
+	"github.com/aws/aws-lambda-go/events"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// event is just enough of any event to tell which kind it is
+type event struct {
+	Version        string `json:"version"`
+	RequestContext struct {
+		ELB *events.ELBContext `json:"elb"`
+	} `json:"requestContext"`
+}
+
+// Handler takes the routes of an API and returns a lambda handler which
+// accepts REST API (v1), HTTP API (v2), ALB and Function URL events,
+// working out which from the event itself, and responding in the shape
+// that kind of event expects. Function URLs send the same payload as
+// HTTP APIs, so they're handled the same way.
+func Handler(routes []api.Route) func(context.Context, json.RawMessage) (interface{}, error) {
+	v1 := Router(routes)
+	return func(ctx context.Context, payload json.RawMessage) (interface{}, error) {
+		e := event{}
+		if err := json.Unmarshal(payload, &e); err != nil {
+			return nil, err
+		}
+
+		switch {
+		case e.RequestContext.ELB != nil:
+			req := events.ALBTargetGroupRequest{}
+			if err := json.Unmarshal(payload, &req); err != nil {
+				return nil, err
+			}
+			return serveALB(ctx, routes, req), nil
+
+		case e.Version == "2.0":
+			req := events.APIGatewayV2HTTPRequest{}
+			if err := json.Unmarshal(payload, &req); err != nil {
+				return nil, err
+			}
+			return serveV2(ctx, routes, req), nil
+
+		default:
+			req := Request{}
+			if err := json.Unmarshal(payload, &req); err != nil {
+				return nil, err
+			}
+			return v1(ctx, req)
+		}
+	}
+}
+
+// serveV2 serves HTTP API, and Function URL, events
+func serveV2(ctx context.Context, routes []api.Route, e events.APIGatewayV2HTTPRequest) events.APIGatewayV2HTTPResponse {
+	body, err := decodeBody(e.Body, e.IsBase64Encoded)
+
+	headers := make(map[string]string, len(e.Headers)+1)
+	for key, value := range e.Headers {
+		headers[key] = value
+	}
+	if len(e.Cookies) > 0 {
+		// Cookies are split out of the headers in v2
+		headers["cookie"] = strings.Join(e.Cookies, "; ")
+	}
+
+	// Query parameters sent more than once are comma separated
+	query := make(map[string]string, len(e.QueryStringParameters))
+	for key, value := range e.QueryStringParameters {
+		query[key] = strings.SplitN(value, ",", 2)[0]
+	}
+
+	req := api.Request{
+		Method:     e.RequestContext.HTTP.Method,
+		Path:       e.RawPath,
+		PathParams: e.PathParameters,
+		Query:      query,
+		Headers:    headers,
+		Body:       body,
+		RequestID:  e.RequestContext.RequestID,
+	}
+
+	var res api.Response
+	if err != nil {
+		res = api.Fail(req, ErrMalformedEvent)
+	} else {
+		res = serve(ctx, routes, req, resource(e.RouteKey))
+	}
+
+	return events.APIGatewayV2HTTPResponse{
+		StatusCode: res.Status,
+		Headers:    res.Headers,
+		Cookies:    res.Cookies,
+		Body:       string(res.Body),
+	}
+}
+
+// resource is the path of a route key, e.g. "GET /users/{id}", the
+// $default route, which Function URLs always use, doesn't have one
+func resource(routeKey string) string {
+	parts := strings.SplitN(routeKey, " ", 2)
+	if len(parts) != 2 {
+		return ""
+	}
+	return parts[1]
+}
+
+// serveALB serves ALB events. Target groups either send and expect
+// multi-value headers or single ones, depending on how they're set up.
+func serveALB(ctx context.Context, routes []api.Route, e events.ALBTargetGroupRequest) events.ALBTargetGroupResponse {
+	body, err := decodeBody(e.Body, e.IsBase64Encoded)
+
+	// ALB leaves query parameters encoded as they were sent
+	query := singleValued(e.QueryStringParameters, e.MultiValueQueryStringParameters)
+	for key, value := range query {
+		if unescaped, err := url.QueryUnescape(value); err == nil {
+			query[key] = unescaped
+		}
+	}
+
+	headers := singleValued(e.Headers, e.MultiValueHeaders)
+	req := api.Request{
+		Method:    e.HTTPMethod,
+		Path:      e.Path,
+		Query:     query,
+		Headers:   headers,
+		Body:      body,
+		RequestID: headers["x-amzn-trace-id"],
+	}
+
+	var res api.Response
+	if err != nil {
+		res = api.Fail(req, ErrMalformedEvent)
+	} else {
+		res = serve(ctx, routes, req, "")
+	}
+
+	r := events.ALBTargetGroupResponse{
+		StatusCode:        res.Status,
+		StatusDescription: fmt.Sprintf("%d %s", res.Status, http.StatusText(res.Status)),
+		Body:              string(res.Body),
+	}
+
+	if len(e.MultiValueHeaders) == 0 {
+		r.Headers = res.Headers
+		if len(res.Cookies) > 0 {
+			// Without multi-value headers, only one cookie can be set
+			r.Headers = withHeader(res.Headers, "Set-Cookie", res.Cookies[0])
+		}
+		return r
+	}
+
+	r.MultiValueHeaders = make(map[string][]string, len(res.Headers)+1)
+	for key, value := range res.Headers {
+		r.MultiValueHeaders[key] = []string{value}
+	}
+	if len(res.Cookies) > 0 {
+		r.MultiValueHeaders["Set-Cookie"] = res.Cookies
+	}
+	return r
+}
+
+func withHeader(headers map[string]string, key, value string) map[string]string {
+	with := make(map[string]string, len(headers)+1)
+	for k, v := range headers {
+		with[k] = v
+	}
+	with[key] = value
+	return with
+}
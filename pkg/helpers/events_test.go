@@ -0,0 +1,119 @@
+package helpers
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/EwanValentine/serverless-api-example/pkg/api"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+)
+
+func cookies(ctx context.Context, req api.Request) api.Response {
+	return api.Response{
+		Status:  http.StatusOK,
+		Headers: map[string]string{"X-Cookie": req.Header("Cookie")},
+		Cookies: []string{"a=1", "b=2"},
+	}
+}
+
+var eventRoutes = append([]api.Route{{Method: "GET", Path: "/cookies", Handler: cookies}}, routes...)
+
+func handle(t *testing.T, payload string) interface{} {
+	res, err := Handler(eventRoutes)(context.Background(), json.RawMessage(payload))
+	assert.NoError(t, err)
+	return res
+}
+
+func TestHandlerAcceptsRESTAPIEvents(t *testing.T) {
+	res := handle(t, `{
+		"resource": "/users/{id}",
+		"path": "/users/abc123",
+		"httpMethod": "GET",
+		"pathParameters": {"id": "abc123"},
+		"multiValueQueryStringParameters": {"cursor": ["next", "other"]}
+	}`)
+	assert.Equal(t, `{"cursor":"next","id":"abc123"}`, res.(Response).Body)
+
+	res = handle(t, `{"resource": "/cookies", "path": "/cookies", "httpMethod": "GET"}`)
+	assert.Equal(t, []string{"a=1", "b=2"}, res.(Response).MultiValueHeaders["Set-Cookie"])
+}
+
+func TestHandlerAcceptsHTTPAPIEvents(t *testing.T) {
+	res := handle(t, `{
+		"version": "2.0",
+		"routeKey": "GET /users/{id}",
+		"rawPath": "/users/abc123",
+		"rawQueryString": "cursor=next&cursor=other",
+		"queryStringParameters": {"cursor": "next,other"},
+		"pathParameters": {"id": "abc123"},
+		"requestContext": {"http": {"method": "GET"}}
+	}`).(events.APIGatewayV2HTTPResponse)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, `{"cursor":"next","id":"abc123"}`, res.Body)
+
+	res = handle(t, `{
+		"version": "2.0",
+		"routeKey": "GET /cookies",
+		"rawPath": "/cookies",
+		"cookies": ["session=abc", "theme=dark"],
+		"requestContext": {"http": {"method": "GET"}}
+	}`).(events.APIGatewayV2HTTPResponse)
+	assert.Equal(t, "session=abc; theme=dark", res.Headers["X-Cookie"])
+	assert.Equal(t, []string{"a=1", "b=2"}, res.Cookies)
+}
+
+func TestHandlerRoutesHTTPAPIProxyRoutesByThePath(t *testing.T) {
+	res := handle(t, `{
+		"version": "2.0",
+		"routeKey": "ANY /users/{proxy+}",
+		"rawPath": "/users/abc123",
+		"pathParameters": {"proxy": "abc123"},
+		"requestContext": {"http": {"method": "GET"}}
+	}`).(events.APIGatewayV2HTTPResponse)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, `{"cursor":"","id":"abc123"}`, res.Body)
+}
+
+func TestHandlerAcceptsFunctionURLEvents(t *testing.T) {
+	res := handle(t, `{
+		"version": "2.0",
+		"routeKey": "$default",
+		"rawPath": "/users/abc123",
+		"requestContext": {"domainName": "abc.lambda-url.eu-west-1.on.aws", "http": {"method": "DELETE"}}
+	}`).(events.APIGatewayV2HTTPResponse)
+	assert.Equal(t, `{"cursor":"","id":"abc123"}`, res.Body)
+
+	res = handle(t, `{
+		"version": "2.0",
+		"routeKey": "$default",
+		"rawPath": "/users/abc123",
+		"requestContext": {"http": {"method": "PUT"}}
+	}`).(events.APIGatewayV2HTTPResponse)
+	assert.Equal(t, http.StatusMethodNotAllowed, res.StatusCode)
+	assert.Equal(t, "DELETE, GET", res.Headers["Allow"])
+}
+
+func TestHandlerAcceptsALBEvents(t *testing.T) {
+	res := handle(t, `{
+		"requestContext": {"elb": {"targetGroupArn": "arn:aws:elasticloadbalancing:eu-west-1:123456789012:targetgroup/users/abc"}},
+		"httpMethod": "GET",
+		"path": "/users/abc123",
+		"queryStringParameters": {"cursor": "a%20b"},
+		"headers": {"x-amzn-trace-id": "Root=1-abc"}
+	}`).(events.ALBTargetGroupResponse)
+	assert.Equal(t, "200 OK", res.StatusDescription)
+	assert.Equal(t, `{"cursor":"a b","id":"abc123"}`, res.Body)
+	assert.Nil(t, res.MultiValueHeaders)
+
+	res = handle(t, `{
+		"requestContext": {"elb": {"targetGroupArn": "arn:aws:elasticloadbalancing:eu-west-1:123456789012:targetgroup/users/abc"}},
+		"httpMethod": "GET",
+		"path": "/cookies",
+		"multiValueHeaders": {"cookie": ["session=abc"]}
+	}`).(events.ALBTargetGroupResponse)
+	assert.Nil(t, res.Headers)
+	assert.Equal(t, []string{"session=abc"}, res.MultiValueHeaders["X-Cookie"])
+	assert.Equal(t, []string{"a=1", "b=2"}, res.MultiValueHeaders["Set-Cookie"])
+}
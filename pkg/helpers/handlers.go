@@ -1,39 +1,96 @@
 package helpers
 
 import (
-	"encoding/json"
+	"encoding/base64"
+	"github.com/EwanValentine/serverless-api-example/pkg/api"
 	"github.com/aws/aws-lambda-go/events"
-	"net/http"
+	"net/url"
+	"strings"
 )
 
+// Request is a REST API (v1) event
 type Request events.APIGatewayProxyRequest
 
-type Response events.APIGatewayProxyResponse
+// Header gets a request header, regardless of how the client cased it
+func (r Request) Header(name string) string {
+	for key, value := range r.Headers {
+		if strings.EqualFold(key, name) {
+			return value
+		}
+	}
+	return ""
+}
 
-// Fail returns an internal server error with the error message
-func Fail(err error, status int) (Response, error) {
-	e := make(map[string]string, 0)
-	e["message"] = err.Error()
+// api turns the event into a transport neutral request
+func (r Request) api() (api.Request, error) {
+	body, err := decodeBody(r.Body, r.IsBase64Encoded)
+	if err != nil {
+		return api.Request{}, err
+	}
 
-	// We don't need to worry about this error,
-	// as we're controlling the input.
-	body, _ := json.Marshal(e)
+	headers := singleValued(r.Headers, r.MultiValueHeaders)
+	path, query := r.Path, singleValued(r.QueryStringParameters, r.MultiValueQueryStringParameters)
+	if i := strings.Index(path, "?"); i >= 0 {
+		// Hand written events sometimes keep the query on the path
+		values, err := url.ParseQuery(path[i+1:])
+		if err != nil {
+			return api.Request{}, err
+		}
 
-	return Response{
-		Body:       string(body),
-		StatusCode: status,
+		for key := range values {
+			if _, ok := query[key]; !ok {
+				query[key] = values.Get(key)
+			}
+		}
+		path = path[:i]
+	}
+
+	return api.Request{
+		Method:     r.HTTPMethod,
+		Path:       path,
+		PathParams: r.PathParameters,
+		Query:      query,
+		Headers:    headers,
+		Body:       body,
+		RequestID:  r.RequestContext.RequestID,
 	}, nil
 }
 
-// Success returns a valid response
-func Success(data interface{}, status int) (Response, error) {
-	body, err := json.Marshal(data)
-	if err != nil {
-		return Fail(err, http.StatusInternalServerError)
+// Response is a REST API (v1) response
+type Response events.APIGatewayProxyResponse
+
+// response turns a transport neutral response into one API Gateway understands
+func response(res api.Response) Response {
+	r := Response{
+		StatusCode: res.Status,
+		Headers:    res.Headers,
+		Body:       string(res.Body),
+	}
+	if len(res.Cookies) > 0 {
+		r.MultiValueHeaders = map[string][]string{"Set-Cookie": res.Cookies}
 	}
+	return r
+}
 
-	return Response{
-		Body:       string(body),
-		StatusCode: status,
-	}, nil
+func decodeBody(body string, isBase64Encoded bool) ([]byte, error) {
+	if !isBase64Encoded {
+		return []byte(body), nil
+	}
+	return base64.StdEncoding.DecodeString(body)
+}
+
+// singleValued merges single and multi-value headers, or query
+// parameters, taking the first value of those sent more than once.
+// Events have either, or both, depending on how they're configured.
+func singleValued(single map[string]string, multi map[string][]string) map[string]string {
+	values := make(map[string]string, len(single)+len(multi))
+	for key, value := range multi {
+		if len(value) > 0 {
+			values[key] = value[0]
+		}
+	}
+	for key, value := range single {
+		values[key] = value
+	}
+	return values
 }
@@ -2,56 +2,83 @@ package helpers
 
 import (
 	"context"
-	"errors"
+	"github.com/EwanValentine/serverless-api-example/pkg/api"
+	"github.com/EwanValentine/serverless-api-example/pkg/apperrors"
+	"github.com/EwanValentine/serverless-api-example/pkg/logging"
+	"github.com/aws/aws-lambda-go/lambdacontext"
+	"go.uber.org/zap"
+	"strings"
 	"time"
 )
 
-type handler interface {
-	GetAll(ctx context.Context) (Response, error)
-	Get(ctx context.Context, id string) (Response, error)
-	Create(ctx context.Context, body []byte) (Response, error)
-	Update(ctx context.Context, id string, body []byte) (Response, error)
-	Delete(ctx context.Context, id string) (Response, error)
-}
+// DeadlineMargin is how long before lambda's deadline requests are
+// cancelled, leaving time to respond before the function is killed
+var DeadlineMargin = time.Millisecond * 500
 
-const fiveSecondsTimeout = time.Second * 5
+// ErrMalformedEvent is returned for events we can't make sense of
+var ErrMalformedEvent = apperrors.New(apperrors.Validation, "malformed request")
 
-// Router takes a lambda handler and returns a higher order function
-// which can route each request using the HTTP verb and path params.
-func Router(handler handler) func(context.Context, Request) (Response, error) {
+// Router takes the routes of an API and returns a lambda handler which
+// routes each request by its HTTP verb and path. API Gateway tells us
+// the resource, as declared in serverless.yml, the request matched, so
+// that's routed on when it's there, otherwise the path is. Requests no
+// route matches get a 404, or a 405 when only the method doesn't match.
+func Router(routes []api.Route) func(context.Context, Request) (Response, error) {
 	return func(ctx context.Context, req Request) (Response, error) {
+		r, err := req.api()
+		if err != nil {
+			return response(api.Fail(r, ErrMalformedEvent)), nil
+		}
+
+		return response(serve(ctx, routes, r, req.Resource)), nil
+	}
+}
 
-		// Add cancellation deadline to context
-		ctx, cancel := context.WithTimeout(ctx, fiveSecondsTimeout)
+// serve the request with the matching route, by the resource it was
+// made to, when the event tells us, or else its path. Proxy resources,
+// e.g. /{proxy+}, stand for many of our routes, so those go by the path.
+// Lambda's deadline is brought forward by the DeadlineMargin.
+func serve(ctx context.Context, routes []api.Route, req api.Request, resource string) api.Response {
+	if deadline, ok := ctx.Deadline(); ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, deadline.Add(-DeadlineMargin))
 		defer cancel()
+	}
 
-		switch req.HTTPMethod {
-		case "GET":
-			id, ok := req.PathParameters["id"]
-			if !ok {
-				return handler.GetAll(ctx)
-			}
-			return handler.Get(ctx, id)
-
-		case "POST":
-			return handler.Create(ctx, []byte(req.Body))
-
-		case "PUT":
-			id, ok := req.PathParameters["id"]
-			if !ok {
-				return Response{}, errors.New("id parameter missing")
-			}
-			return handler.Update(ctx, id, []byte(req.Body))
-
-		case "DELETE":
-			id, ok := req.PathParameters["id"]
-			if !ok {
-				return Response{}, errors.New("id parameter missing")
-			}
-			return handler.Delete(ctx, id)
-
-		default:
-			return Response{}, errors.New("invalid method")
-		}
+	ctx = withLogger(ctx, req)
+	if resource == "" || greedy(resource) {
+		return api.Serve(ctx, routes, req)
+	}
+
+	// Matching the resource against the patterns picks the same
+	// route, API Gateway has already worked out the parameters.
+	route, _, allowed := api.Match(routes, req.Method, resource)
+	if route == nil {
+		return api.NoRoute(req, allowed)
 	}
+	return route.Serve(ctx, req)
+}
+
+// greedy is whether the resource has a greedy path parameter, e.g. {proxy+}
+func greedy(resource string) bool {
+	return strings.Contains(resource, "+}")
+}
+
+// withLogger logs with the lambda request ID, and the X-Ray trace ID,
+// which lambda gives us, or else the load balancer forwards
+func withLogger(ctx context.Context, req api.Request) context.Context {
+	var fields []zap.Field
+	if lc, ok := lambdacontext.FromContext(ctx); ok {
+		fields = append(fields, zap.String("lambdaRequestId", lc.AwsRequestID))
+	}
+
+	trace, _ := ctx.Value("x-amzn-trace-id").(string)
+	if trace == "" {
+		trace = req.Header("X-Amzn-Trace-Id")
+	}
+	if trace != "" {
+		fields = append(fields, zap.String("traceId", logging.TraceID(trace)))
+	}
+
+	return logging.With(ctx, fields...)
 }
@@ -0,0 +1,115 @@
+package helpers
+
+import (
+	"context"
+	"github.com/EwanValentine/serverless-api-example/pkg/api"
+	"github.com/EwanValentine/serverless-api-example/pkg/logging"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambdacontext"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func echo(ctx context.Context, req api.Request) api.Response {
+	return api.JSON(http.StatusOK, map[string]interface{}{
+		"id":     req.PathParams["id"],
+		"cursor": req.Query["cursor"],
+	})
+}
+
+var routes = []api.Route{
+	{Method: "GET", Path: "/users/{id}", Handler: echo},
+	{Method: "DELETE", Path: "/users/{id}", Handler: echo},
+}
+
+func TestRouterUsesTheResource(t *testing.T) {
+	// Custom domains can put a base path in front of the resource
+	res, err := Router(routes)(context.Background(), Request{
+		HTTPMethod:     "GET",
+		Resource:       "/users/{id}",
+		Path:           "/v1/users/abc123",
+		PathParameters: map[string]string{"id": "abc123"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, `{"cursor":"","id":"abc123"}`, res.Body)
+}
+
+func TestRouterRoutesProxyResourcesByThePath(t *testing.T) {
+	for _, resource := range []string{"/{proxy+}", "/users/{proxy+}"} {
+		res, err := Router(routes)(context.Background(), Request{
+			HTTPMethod:     "GET",
+			Resource:       resource,
+			Path:           "/users/abc123",
+			PathParameters: map[string]string{"proxy": "users/abc123"},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, `{"cursor":"","id":"abc123"}`, res.Body, resource)
+	}
+}
+
+func TestRouterFallsBackToThePath(t *testing.T) {
+	res, err := Router(routes)(context.Background(), Request{
+		HTTPMethod: "GET",
+		Path:       "/users/abc123?cursor=next",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, `{"cursor":"next","id":"abc123"}`, res.Body)
+}
+
+func TestRouterRespondsToUnknownRoutes(t *testing.T) {
+	res, err := Router(routes)(context.Background(), Request{HTTPMethod: "PUT", Path: "/users/abc123"})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusMethodNotAllowed, res.StatusCode)
+	assert.Equal(t, "DELETE, GET", res.Headers["Allow"])
+
+	res, err = Router(routes)(context.Background(), Request{HTTPMethod: "GET", Path: "/nothing"})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, res.StatusCode)
+}
+
+func TestRouterLeavesAMarginBeforeTheDeadline(t *testing.T) {
+	deadline := time.Now().Add(time.Second * 3)
+	ctx, cancel := context.WithDeadline(context.Background(), deadline)
+	defer cancel()
+
+	var got time.Time
+	routes := []api.Route{{Method: "GET", Path: "/users", Handler: func(ctx context.Context, req api.Request) api.Response {
+		got, _ = ctx.Deadline()
+		return api.JSON(http.StatusOK, nil)
+	}}}
+
+	_, err := Router(routes)(ctx, Request{HTTPMethod: "GET", Path: "/users"})
+	assert.NoError(t, err)
+	assert.Equal(t, deadline.Add(-DeadlineMargin), got)
+}
+
+func TestRouterLogsWithTheRequest(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	ctx := logging.NewContext(context.Background(), zap.New(core))
+	ctx = lambdacontext.NewContext(ctx, &lambdacontext.LambdaContext{AwsRequestID: "lambda-1"})
+	ctx = context.WithValue(ctx, "x-amzn-trace-id", "Root=1-5759e988-bd862e3fe1be46a994272793;Sampled=1")
+
+	routes := []api.Route{{Method: "GET", Path: "/users/{id}", Handler: func(ctx context.Context, req api.Request) api.Response {
+		logging.Logger(ctx).Info("handled")
+		return api.JSON(http.StatusOK, nil)
+	}}}
+
+	Router(routes)(ctx, Request{
+		HTTPMethod:     "GET",
+		Resource:       "/users/{id}",
+		Path:           "/users/abc123",
+		RequestContext: events.APIGatewayProxyRequestContext{RequestID: "req-1"},
+	})
+	assert.Equal(t, map[string]interface{}{
+		"lambdaRequestId": "lambda-1",
+		"traceId":         "1-5759e988-bd862e3fe1be46a994272793",
+		"method":          "GET",
+		"route":           "/users/{id}",
+		"requestId":       "req-1",
+	}, logs.All()[0].ContextMap())
+}
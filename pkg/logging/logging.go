@@ -0,0 +1,48 @@
+// Package logging carries a logger in the context, so each layer can
+// add what it knows about the request, and everything logged while
+// handling it can be tied back to the request.
+package logging
+
+import (
+	"context"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+type key struct{}
+
+// NewContext returns a copy of ctx carrying the logger
+func NewContext(ctx context.Context, logger *zap.Logger) context.Context {
+	return context.WithValue(ctx, key{}, logger)
+}
+
+// FromContext gets the logger ctx carries, if any
+func FromContext(ctx context.Context) (*zap.Logger, bool) {
+	logger, ok := ctx.Value(key{}).(*zap.Logger)
+	return logger, ok
+}
+
+// Logger carried by ctx, or else zap's global logger
+func Logger(ctx context.Context) *zap.Logger {
+	if logger, ok := FromContext(ctx); ok {
+		return logger
+	}
+	return zap.L()
+}
+
+// With returns a copy of ctx, whose logger adds the fields
+func With(ctx context.Context, fields ...zap.Field) context.Context {
+	return NewContext(ctx, Logger(ctx).With(fields...))
+}
+
+// TraceID is the root of an X-Ray trace header, e.g.
+// Root=1-5759e988-bd862e3fe1be46a994272793;Parent=53995c3f42cd8ad8;Sampled=1
+func TraceID(header string) string {
+	for _, part := range strings.Split(header, ";") {
+		if strings.HasPrefix(part, "Root=") {
+			return strings.TrimPrefix(part, "Root=")
+		}
+	}
+	return header
+}
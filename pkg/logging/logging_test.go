@@ -0,0 +1,35 @@
+package logging
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestWithAddsFields(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	ctx := NewContext(context.Background(), zap.New(core))
+
+	ctx = With(ctx, zap.String("requestId", "req-1"))
+	ctx = With(ctx, zap.String("route", "/users/{id}"))
+	Logger(ctx).Info("hello")
+
+	assert.Equal(t, map[string]interface{}{"requestId": "req-1", "route": "/users/{id}"}, logs.All()[0].ContextMap())
+}
+
+func TestLoggerFallsBackToTheGlobalLogger(t *testing.T) {
+	_, ok := FromContext(context.Background())
+	assert.False(t, ok)
+	assert.Equal(t, zap.L(), Logger(context.Background()))
+}
+
+func TestTraceID(t *testing.T) {
+	assert.Equal(t, "1-5759e988-bd862e3fe1be46a994272793", TraceID("Root=1-5759e988-bd862e3fe1be46a994272793;Parent=53995c3f42cd8ad8;Sampled=1"))
+
+	// Load balancers add their own segment in front
+	assert.Equal(t, "1-67891233-abcdef012345678912345678", TraceID("Self=1-67891234-12456789abcdef012345678;Root=1-67891233-abcdef012345678912345678"))
+}
@@ -0,0 +1,105 @@
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/EwanValentine/serverless-api-example/pkg/api"
+	"github.com/aws/aws-lambda-go/lambdacontext"
+)
+
+// emfMetric is a metric declared in an embedded metric format log
+type emfMetric struct {
+	Name string
+	Unit string
+}
+
+// emfDirective tells CloudWatch which properties of a log are metrics
+type emfDirective struct {
+	Namespace  string
+	Dimensions [][]string
+	Metrics    []emfMetric
+}
+
+type emfMetadata struct {
+	Timestamp         int64
+	CloudWatchMetrics []emfDirective
+}
+
+// Metric is a value measured, in one of CloudWatch's units, e.g. Count
+type Metric struct {
+	Name  string
+	Unit  string
+	Value float64
+}
+
+// EMF writes metrics to w, in CloudWatch's embedded metric format, see
+// https://docs.aws.amazon.com/AmazonCloudWatch/latest/monitoring/CloudWatch_Embedded_Metric_Format_Specification.html
+// Lambda sends what functions write to stdout to CloudWatch,
+// which extracts the metrics from the logs.
+type EMF struct {
+	mu        sync.Mutex
+	w         io.Writer
+	namespace string
+}
+
+// NewEMF writing metrics to w, under the namespace
+func NewEMF(w io.Writer, namespace string) *EMF {
+	return &EMF{w: w, namespace: namespace}
+}
+
+// Write a log of the metrics, aggregated by each set of dimensions,
+// which are named from the properties. The lambda request ID is
+// added to the properties, when ctx has one.
+func (e *EMF) Write(ctx context.Context, dimensions [][]string, properties map[string]string, metrics ...Metric) {
+	directive := emfDirective{Namespace: e.namespace, Dimensions: dimensions}
+	l := map[string]interface{}{}
+	for name, value := range properties {
+		l[name] = value
+	}
+	for _, metric := range metrics {
+		directive.Metrics = append(directive.Metrics, emfMetric{Name: metric.Name, Unit: metric.Unit})
+		l[metric.Name] = metric.Value
+	}
+	if lc, ok := lambdacontext.FromContext(ctx); ok {
+		l["RequestID"] = lc.AwsRequestID
+	}
+	l["_aws"] = emfMetadata{
+		Timestamp:         time.Now().UnixNano() / int64(time.Millisecond),
+		CloudWatchMetrics: []emfDirective{directive},
+	}
+
+	// We don't need to worry about this error,
+	// as we're controlling the input.
+	line, _ := json.Marshal(l)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.w.Write(append(line, '\n'))
+}
+
+// Routes logging the metrics of each request they handle
+func (e *EMF) Routes(routes []api.Route) []api.Route {
+	dimensions := [][]string{{"Method", "Route"}, {"Method", "Route", "Status"}}
+	return instrument(routes, func(ctx context.Context, route api.Route, res api.Response, took time.Duration) {
+		errors := 0.0
+		if res.Status >= http.StatusBadRequest {
+			errors = 1
+		}
+
+		e.Write(ctx, dimensions, map[string]string{
+			"Method": route.Method,
+			"Route":  route.Path,
+			"Status": strconv.Itoa(res.Status),
+		},
+			Metric{Name: "Requests", Unit: "Count", Value: 1},
+			Metric{Name: "Errors", Unit: "Count", Value: errors},
+			Metric{Name: "Latency", Unit: "Milliseconds", Value: float64(took) / float64(time.Millisecond)},
+		)
+	})
+}
@@ -0,0 +1,43 @@
+// Package metrics measures the requests each route handles, as
+// Prometheus metrics to be scraped, or CloudWatch embedded metric
+// format logs on Lambda, where there's nothing to scrape.
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/EwanValentine/serverless-api-example/pkg/api"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Register the collector with reg, returning the collector already
+// registered instead, when there is one, so setting up twice is fine
+func Register(reg prometheus.Registerer, collector prometheus.Collector) prometheus.Collector {
+	if err := reg.Register(collector); err != nil {
+		if registered, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			return registered.ExistingCollector
+		}
+		panic(err)
+	}
+	return collector
+}
+
+// observer is told about each response a route makes, and how long it took
+type observer func(ctx context.Context, route api.Route, res api.Response, took time.Duration)
+
+// instrument the routes, so the observer sees each response they make
+func instrument(routes []api.Route, observe observer) []api.Route {
+	instrumented := make([]api.Route, len(routes))
+	for i, route := range routes {
+		route, handler := route, route.Handler
+		route.Handler = func(ctx context.Context, req api.Request) api.Response {
+			start := time.Now()
+			res := handler(ctx, req)
+			observe(ctx, route, res, time.Since(start))
+			return res
+		}
+		instrumented[i] = route
+	}
+	return instrumented
+}
@@ -0,0 +1,87 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/EwanValentine/serverless-api-example/pkg/api"
+	"github.com/aws/aws-lambda-go/lambdacontext"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func respond(status int) api.Handler {
+	return func(ctx context.Context, req api.Request) api.Response {
+		return api.Response{Status: status}
+	}
+}
+
+var routes = []api.Route{
+	{Method: "GET", Path: "/users/{id}", Handler: respond(http.StatusOK)},
+	{Method: "DELETE", Path: "/users/{id}", Handler: respond(http.StatusNotFound)},
+}
+
+func TestHTTPCountsRequestsByRouteAndStatus(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	m := NewHTTP(reg)
+	instrumented := m.Routes(routes)
+
+	ctx := context.Background()
+	api.Serve(ctx, instrumented, api.Request{Method: "GET", Path: "/users/a"})
+	api.Serve(ctx, instrumented, api.Request{Method: "GET", Path: "/users/b"})
+	api.Serve(ctx, instrumented, api.Request{Method: "DELETE", Path: "/users/a"})
+
+	assert.Equal(t, 2.0, testutil.ToFloat64(m.requests.WithLabelValues("GET", "/users/{id}", "200")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.requests.WithLabelValues("DELETE", "/users/{id}", "404")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.errors.WithLabelValues("DELETE", "/users/{id}", "404")))
+	assert.Equal(t, 0.0, testutil.ToFloat64(m.errors.WithLabelValues("GET", "/users/{id}", "200")))
+	assert.Equal(t, 2, testutil.CollectAndCount(m.duration))
+
+	// Latency is by status too
+	families, err := reg.Gather()
+	assert.NoError(t, err)
+	for _, family := range families {
+		if family.GetName() != "http_request_duration_seconds" {
+			continue
+		}
+		var statuses []string
+		for _, metric := range family.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if label.GetName() == "status" {
+					statuses = append(statuses, label.GetValue())
+				}
+			}
+		}
+		assert.ElementsMatch(t, []string{"200", "404"}, statuses)
+	}
+
+	// Setting up again shares the metrics already registered
+	again := NewHTTP(reg)
+	assert.Equal(t, 2.0, testutil.ToFloat64(again.requests.WithLabelValues("GET", "/users/{id}", "200")))
+}
+
+func TestEMFLogsEachRequest(t *testing.T) {
+	var out bytes.Buffer
+	instrumented := NewEMF(&out, "test").Routes(routes)
+
+	ctx := lambdacontext.NewContext(context.Background(), &lambdacontext.LambdaContext{AwsRequestID: "lambda-1"})
+	api.Serve(ctx, instrumented, api.Request{Method: "DELETE", Path: "/users/a"})
+
+	var l map[string]interface{}
+	assert.NoError(t, json.Unmarshal(out.Bytes(), &l))
+	assert.Equal(t, "DELETE", l["Method"])
+	assert.Equal(t, "/users/{id}", l["Route"])
+	assert.Equal(t, "404", l["Status"])
+	assert.Equal(t, "lambda-1", l["RequestID"])
+	assert.Equal(t, 1.0, l["Requests"])
+	assert.Equal(t, 1.0, l["Errors"])
+	assert.Contains(t, l, "Latency")
+
+	directive := l["_aws"].(map[string]interface{})["CloudWatchMetrics"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, "test", directive["Namespace"])
+	assert.Len(t, directive["Metrics"], 3)
+}
@@ -0,0 +1,53 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/EwanValentine/serverless-api-example/pkg/api"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// HTTP metrics, by method, route and status
+type HTTP struct {
+	requests *prometheus.CounterVec
+	errors   *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+}
+
+// NewHTTP metrics, registered with reg
+func NewHTTP(reg prometheus.Registerer) *HTTP {
+	return &HTTP{
+		requests: Register(reg, prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "http_requests_total",
+			Help: "Requests handled, by method, route and status.",
+		}, []string{"method", "route", "status"})).(*prometheus.CounterVec),
+
+		errors: Register(reg, prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "http_request_errors_total",
+			Help: "Requests which failed, with a 4xx or 5xx status, by method, route and status.",
+		}, []string{"method", "route", "status"})).(*prometheus.CounterVec),
+
+		duration: Register(reg, prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "http_request_duration_seconds",
+			Help:    "How long requests took to handle, by method, route and status.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"method", "route", "status"})).(*prometheus.HistogramVec),
+	}
+}
+
+// Routes measured by the metrics
+func (m *HTTP) Routes(routes []api.Route) []api.Route {
+	return instrument(routes, m.observe)
+}
+
+func (m *HTTP) observe(ctx context.Context, route api.Route, res api.Response, took time.Duration) {
+	status := strconv.Itoa(res.Status)
+	m.requests.WithLabelValues(route.Method, route.Path, status).Inc()
+	m.duration.WithLabelValues(route.Method, route.Path, status).Observe(took.Seconds())
+	if res.Status >= http.StatusBadRequest {
+		m.errors.WithLabelValues(route.Method, route.Path, status).Inc()
+	}
+}
@@ -0,0 +1,146 @@
+// Package redact keeps personal information, such as emails and names,
+// out of logs and error responses. Emails are found wherever they
+// appear, other values are redacted once they're known, from the
+// fields of entities tagged with pii, e.g.
+//
+//	Name string `json:"name" pii:"name"`
+package redact
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync/atomic"
+)
+
+// Mode is how much of personal information is redacted
+type Mode string
+
+const (
+	// Off leaves personal information as is, for local development
+	Off Mode = "off"
+
+	// Mask keeps enough to tell values apart, e.g. e***@example.com
+	Mask Mode = "mask"
+
+	// Full replaces personal information altogether
+	Full Mode = "full"
+)
+
+// Redacted replaces values in Full mode
+const Redacted = "[redacted]"
+
+var mode atomic.Value
+
+func init() {
+	mode.Store(Mask)
+}
+
+// ParseMode from config, defaulting to Mask
+func ParseMode(s string) (Mode, error) {
+	switch m := Mode(strings.ToLower(strings.TrimSpace(s))); m {
+	case "":
+		return Mask, nil
+	case Off, Mask, Full:
+		return m, nil
+	}
+	return "", fmt.Errorf("unknown redaction mode %q", s)
+}
+
+// SetMode redaction is done in, everywhere
+func SetMode(m Mode) {
+	mode.Store(m)
+}
+
+// CurrentMode redaction is done in
+func CurrentMode() Mode {
+	return mode.Load().(Mode)
+}
+
+var emails = regexp.MustCompile(`[a-zA-Z0-9._%+\-]+@[a-zA-Z0-9.\-]+\.[a-zA-Z]{2,}`)
+
+// Email redacted, keeping its domain when masking
+func Email(email string) string {
+	switch CurrentMode() {
+	case Off:
+		return email
+	case Full:
+		return Redacted
+	}
+
+	at := strings.LastIndex(email, "@")
+	if at < 1 {
+		return Value(email)
+	}
+	return email[:1] + "***" + email[at:]
+}
+
+// Value redacted, keeping the first letter of each word when masking
+func Value(value string) string {
+	switch CurrentMode() {
+	case Off:
+		return value
+	case Full:
+		return Redacted
+	}
+
+	words := strings.Fields(value)
+	for i, word := range words {
+		words[i] = string([]rune(word)[:1]) + "***"
+	}
+	return strings.Join(words, " ")
+}
+
+// String with any emails in it redacted
+func String(s string) string {
+	if CurrentMode() == Off {
+		return s
+	}
+	return emails.ReplaceAllStringFunc(s, Email)
+}
+
+// Known redacts the emails in s, and any of the known values
+func Known(s string, known []string) string {
+	s = String(s)
+	if CurrentMode() == Off {
+		return s
+	}
+
+	for _, value := range known {
+		s = strings.Replace(s, value, Value(value), -1)
+	}
+	return s
+}
+
+// minLength of values to redact when they're known, shorter
+// values would match too much of what's around them
+const minLength = 3
+
+// Values of the fields of v tagged with pii, which can be a struct,
+// or a pointer to one. Longer values come first, so they're redacted
+// before any values they contain.
+func Values(v interface{}) []string {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	if rv.Kind() != reflect.Struct {
+		return nil
+	}
+
+	var values []string
+	for i := 0; i < rv.NumField(); i++ {
+		if _, ok := rv.Type().Field(i).Tag.Lookup("pii"); !ok {
+			continue
+		}
+
+		field := reflect.Indirect(rv.Field(i))
+		if field.Kind() == reflect.String && len(field.String()) >= minLength {
+			values = append(values, field.String())
+		}
+	}
+
+	sort.SliceStable(values, func(i, j int) bool {
+		return len(values[i]) > len(values[j])
+	})
+	return values
+}
@@ -0,0 +1,87 @@
+package redact
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// in the mode, for the rest of the test
+func in(t *testing.T, m Mode) {
+	previous := CurrentMode()
+	SetMode(m)
+	t.Cleanup(func() { SetMode(previous) })
+}
+
+func TestParseMode(t *testing.T) {
+	for config, expected := range map[string]Mode{"": Mask, "off": Off, "Full": Full} {
+		m, err := ParseMode(config)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, m)
+	}
+
+	_, err := ParseMode("some")
+	assert.Error(t, err)
+}
+
+func TestRedactingInEachMode(t *testing.T) {
+	const s = "ewan@test.com is taken by Ewan Valentine"
+	known := []string{"Ewan Valentine"}
+
+	for m, expected := range map[Mode]string{
+		Off:  s,
+		Mask: "e***@test.com is taken by E*** V***",
+		Full: "[redacted] is taken by [redacted]",
+	} {
+		in(t, m)
+		assert.Equal(t, expected, Known(s, known), m)
+	}
+}
+
+func TestValuesAreTaggedFields(t *testing.T) {
+	email := "longer@test.com"
+	entity := struct {
+		ID    string
+		Name  string  `pii:"name"`
+		Email *string `pii:"email"`
+		Short string  `pii:"name"`
+	}{ID: "abc123", Name: "Ewan", Email: &email, Short: "E"}
+
+	assert.Equal(t, []string{"longer@test.com", "Ewan"}, Values(&entity))
+	assert.Nil(t, Values("not a struct"))
+}
+
+func TestCoreRedactsFieldsAndMessages(t *testing.T) {
+	in(t, Mask)
+	observed, logs := observer.New(zapcore.InfoLevel)
+	logger := Logger(zap.New(Core(observed)), "Ewan").With(zap.String("email", "ewan@test.com"))
+
+	logger.Info("Ewan signed up", zap.Error(errors.New("name Ewan is too long")), zap.String("id", "abc123"))
+
+	entry := logs.All()[0]
+	assert.Equal(t, "E*** signed up", entry.Message)
+	assert.Equal(t, map[string]interface{}{
+		"email": "e***@test.com",
+		"error": "name E*** is too long",
+		"id":    "abc123",
+	}, entry.ContextMap())
+}
+
+func TestCoreKeepsTheWrappedCoresSampling(t *testing.T) {
+	in(t, Mask)
+	observed, logs := observer.New(zapcore.InfoLevel)
+	logger := zap.New(Core(zapcore.NewSampler(observed, time.Minute, 1, 100)))
+
+	for i := 0; i < 3; i++ {
+		logger.Info("Ewan signed up", zap.String("email", "ewan@test.com"))
+	}
+	logger.Debug("below the level")
+
+	assert.Equal(t, 1, logs.Len())
+	assert.Equal(t, "e***@test.com", logs.All()[0].ContextMap()["email"])
+}
@@ -0,0 +1,73 @@
+package redact
+
+import (
+	"fmt"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// core redacts log messages and string fields, before
+// they're written by the core it wraps
+type core struct {
+	zapcore.Core
+	known []string
+}
+
+// Core redacts everything logged through c, e.g. with
+// zap.NewProduction(zap.WrapCore(redact.Core))
+func Core(c zapcore.Core) zapcore.Core {
+	return &core{Core: c}
+}
+
+// Logger which also redacts the known values, on top of emails
+func Logger(logger *zap.Logger, known ...string) *zap.Logger {
+	if len(known) == 0 {
+		return logger
+	}
+
+	return logger.WithOptions(zap.WrapCore(func(c zapcore.Core) zapcore.Core {
+		return &core{Core: c, known: known}
+	}))
+}
+
+func (c *core) With(fields []zapcore.Field) zapcore.Core {
+	return &core{Core: c.Core.With(c.fields(fields)), known: c.known}
+}
+
+// Check asks the wrapped core whether to log the entry, so samplers
+// still drop what they would have, but has it written through us
+func (c *core) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Core.Check(entry, nil) != nil {
+		return checked.AddCore(entry, c)
+	}
+	return checked
+}
+
+func (c *core) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	entry.Message = Known(entry.Message, c.known)
+	return c.Core.Write(entry, c.fields(fields))
+}
+
+// fields with their text redacted, errors are logged as their messages
+func (c *core) fields(fields []zapcore.Field) []zapcore.Field {
+	if CurrentMode() == Off {
+		return fields
+	}
+
+	redacted := make([]zapcore.Field, len(fields))
+	for i, field := range fields {
+		switch field.Type {
+		case zapcore.StringType:
+			field.String = Known(field.String, c.known)
+		case zapcore.ByteStringType:
+			field = zap.String(field.Key, Known(string(field.Interface.([]byte)), c.known))
+		case zapcore.StringerType:
+			field = zap.String(field.Key, Known(field.Interface.(fmt.Stringer).String(), c.known))
+		case zapcore.ErrorType:
+			field = zap.String(field.Key, Known(field.Interface.(error).Error(), c.known))
+		}
+		redacted[i] = field
+	}
+	return redacted
+}
@@ -0,0 +1,69 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/EwanValentine/serverless-api-example/pkg/api"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Name of the tracer spans are started with
+const Name = "github.com/EwanValentine/serverless-api-example"
+
+// headers carries trace context in request headers
+type headers map[string]string
+
+// Get a header, regardless of how it's cased
+func (h headers) Get(key string) string {
+	return api.Request{Headers: h}.Header(key)
+}
+
+// Set a header, replacing it however it's cased
+func (h headers) Set(key, value string) {
+	for existing := range h {
+		if strings.EqualFold(existing, key) {
+			delete(h, existing)
+		}
+	}
+	h[key] = value
+}
+
+// Keys of the headers
+func (h headers) Keys() []string {
+	keys := make([]string, 0, len(h))
+	for key := range h {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// Routes traced, with a span for each request they handle, continuing
+// the trace the caller started, if the request carries its context.
+func Routes(routes []api.Route) []api.Route {
+	traced := make([]api.Route, len(routes))
+	for i, route := range routes {
+		route, handler := route, route.Handler
+		route.Handler = func(ctx context.Context, req api.Request) api.Response {
+			ctx = otel.GetTextMapPropagator().Extract(ctx, headers(req.Headers))
+			ctx, span := otel.Tracer(Name).Start(ctx, route.Method+" "+route.Path,
+				trace.WithSpanKind(trace.SpanKindServer),
+				trace.WithAttributes(semconv.HTTPMethodKey.String(req.Method), semconv.HTTPRouteKey.String(route.Path)),
+			)
+			defer span.End()
+
+			res := handler(ctx, req)
+			span.SetAttributes(semconv.HTTPStatusCodeKey.Int(res.Status))
+			if res.Status >= http.StatusInternalServerError {
+				span.SetStatus(codes.Error, http.StatusText(res.Status))
+			}
+			return res
+		}
+		traced[i] = route
+	}
+	return traced
+}
@@ -0,0 +1,125 @@
+// Package tracing sets up OpenTelemetry, and traces the requests each
+// route handles. Trace context is propagated with W3C traceparent
+// headers, and X-Ray's X-Amzn-Trace-Id, which API Gateway and ALBs send.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"go.opentelemetry.io/contrib/propagators/aws/xray"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+)
+
+// Exporters spans can be sent to
+const (
+	// ExporterNone doesn't export spans, trace context is still propagated
+	ExporterNone = "none"
+
+	// ExporterStdout writes spans to stdout, as they end
+	ExporterStdout = "stdout"
+
+	// ExporterOTLPFile appends spans to a file, as OTLP JSON lines,
+	// which the OpenTelemetry collector can read
+	ExporterOTLPFile = "otlp-file"
+)
+
+// Formats trace IDs are generated in
+const (
+	// IDFormatW3C is random IDs
+	IDFormatW3C = "w3c"
+
+	// IDFormatXRay starts IDs with the time, as X-Ray requires
+	IDFormatXRay = "xray"
+)
+
+// Config of tracing
+type Config struct {
+	Service  string
+	Exporter string
+
+	// File spans are appended to by ExporterOTLPFile
+	File string
+
+	IDFormat string
+}
+
+// ConfigFromEnv reads TRACE_EXPORTER, TRACE_FILE and TRACE_ID_FORMAT.
+// Spans aren't exported by default, and IDs are in X-Ray's format
+// on Lambda, so traces can be sent on to X-Ray.
+func ConfigFromEnv(service string) Config {
+	c := Config{
+		Service:  service,
+		Exporter: os.Getenv("TRACE_EXPORTER"),
+		File:     os.Getenv("TRACE_FILE"),
+		IDFormat: os.Getenv("TRACE_ID_FORMAT"),
+	}
+
+	if c.Exporter == "" {
+		c.Exporter = ExporterNone
+	}
+	if c.File == "" {
+		c.File = "traces.jsonl"
+	}
+	if c.IDFormat == "" {
+		c.IDFormat = IDFormatW3C
+		if os.Getenv("AWS_LAMBDA_FUNCTION_NAME") != "" {
+			c.IDFormat = IDFormatXRay
+		}
+	}
+	return c
+}
+
+// Setup the global tracer provider and propagator, once, when
+// the process starts. Spans are exported as soon as they end,
+// so none are lost when lambda freezes the function between
+// invocations. The exporter is closed by shutdown.
+func Setup(c Config) (shutdown func(context.Context) error, err error) {
+	// Later propagators win, so traceparent is
+	// preferred when a request carries both
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		xray.Propagator{},
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+	if c.Exporter == ExporterNone {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	options := []sdktrace.TracerProviderOption{
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceNameKey.String(c.Service))),
+	}
+	switch c.IDFormat {
+	case IDFormatW3C:
+	case IDFormatXRay:
+		options = append(options, sdktrace.WithIDGenerator(xray.NewIDGenerator()))
+	default:
+		return nil, fmt.Errorf("unknown trace ID format %q", c.IDFormat)
+	}
+
+	var exporter sdktrace.SpanExporter
+	switch c.Exporter {
+	case ExporterStdout:
+		if exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout)); err != nil {
+			return nil, err
+		}
+	case ExporterOTLPFile:
+		f, err := os.OpenFile(c.File, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			return nil, err
+		}
+		exporter = NewOTLPFileExporter(f)
+	default:
+		return nil, fmt.Errorf("unknown trace exporter %q", c.Exporter)
+	}
+
+	provider := sdktrace.NewTracerProvider(append(options, sdktrace.WithSyncer(exporter))...)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
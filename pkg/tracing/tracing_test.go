@@ -0,0 +1,160 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/EwanValentine/serverless-api-example/pkg/api"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// record the spans ended during the test
+func record(t *testing.T) *tracetest.SpanRecorder {
+	_, err := Setup(Config{Exporter: ExporterNone})
+	assert.NoError(t, err)
+
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+	return recorder
+}
+
+func respond(status int) api.Handler {
+	return func(ctx context.Context, req api.Request) api.Response {
+		return api.Response{Status: status}
+	}
+}
+
+var routes = []api.Route{
+	{Method: "GET", Path: "/users/{id}", Handler: respond(http.StatusOK)},
+	{Method: "DELETE", Path: "/users/{id}", Handler: respond(http.StatusInternalServerError)},
+}
+
+func TestRoutesContinueTheCallersTrace(t *testing.T) {
+	recorder := record(t)
+	traced := Routes(routes)
+
+	api.Serve(context.Background(), traced, api.Request{
+		Method:  "GET",
+		Path:    "/users/a",
+		Headers: map[string]string{"Traceparent": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"},
+	})
+
+	spans := recorder.Ended()
+	assert.Len(t, spans, 1)
+	assert.Equal(t, "GET /users/{id}", spans[0].Name())
+	assert.Equal(t, trace.SpanKindServer, spans[0].SpanKind())
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", spans[0].SpanContext().TraceID().String())
+	assert.Equal(t, "00f067aa0ba902b7", spans[0].Parent().SpanID().String())
+	assert.True(t, spans[0].Parent().IsRemote())
+}
+
+func TestRoutesAcceptXRayTraceHeaders(t *testing.T) {
+	recorder := record(t)
+	traced := Routes(routes)
+
+	api.Serve(context.Background(), traced, api.Request{
+		Method:  "GET",
+		Path:    "/users/a",
+		Headers: map[string]string{"X-Amzn-Trace-Id": "Root=1-5759e988-bd862e3fe1be46a994272793;Parent=53995c3f42cd8ad8;Sampled=1"},
+	})
+
+	spans := recorder.Ended()
+	assert.Len(t, spans, 1)
+	assert.Equal(t, "5759e988bd862e3fe1be46a994272793", spans[0].SpanContext().TraceID().String())
+	assert.Equal(t, "53995c3f42cd8ad8", spans[0].Parent().SpanID().String())
+}
+
+func TestRoutesStartNewTracesAndMarkServerErrors(t *testing.T) {
+	recorder := record(t)
+	traced := Routes(routes)
+
+	ctx := context.Background()
+	api.Serve(ctx, traced, api.Request{Method: "GET", Path: "/users/a"})
+	api.Serve(ctx, traced, api.Request{Method: "DELETE", Path: "/users/a"})
+
+	spans := recorder.Ended()
+	assert.Len(t, spans, 2)
+	assert.False(t, spans[0].Parent().IsValid())
+	assert.Equal(t, codes.Unset, spans[0].Status().Code)
+	assert.Equal(t, codes.Error, spans[1].Status().Code)
+}
+
+func TestOTLPFileExporter(t *testing.T) {
+	var buf bytes.Buffer
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(NewOTLPFileExporter(&buf)))
+
+	ctx, parent := provider.Tracer(Name).Start(context.Background(), "parent")
+	_, child := provider.Tracer(Name).Start(ctx, "child")
+	child.SetStatus(codes.Error, "failed")
+	child.End()
+	parent.End()
+
+	lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+	assert.Len(t, lines, 2)
+
+	var traces otlpTraces
+	assert.NoError(t, json.Unmarshal(lines[0], &traces))
+	assert.Len(t, traces.ResourceSpans, 1)
+	assert.Equal(t, Name, traces.ResourceSpans[0].ScopeSpans[0].Scope.Name)
+
+	span := traces.ResourceSpans[0].ScopeSpans[0].Spans[0]
+	assert.Equal(t, "child", span.Name)
+	assert.Equal(t, parent.SpanContext().TraceID().String(), span.TraceID)
+	assert.Equal(t, parent.SpanContext().SpanID().String(), span.ParentSpanID)
+	assert.Equal(t, otlpStatus{Code: otlpStatusError, Message: "failed"}, span.Status)
+}
+
+func TestSetup(t *testing.T) {
+	previous := otel.GetTracerProvider()
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	file := filepath.Join(t.TempDir(), "traces.jsonl")
+	shutdown, err := Setup(Config{Service: "users", Exporter: ExporterOTLPFile, File: file, IDFormat: IDFormatXRay})
+	assert.NoError(t, err)
+
+	_, span := otel.Tracer(Name).Start(context.Background(), "span")
+	span.End()
+
+	contents, err := ioutil.ReadFile(file)
+	assert.NoError(t, err)
+	assert.Contains(t, string(contents), `"service.name"`)
+
+	// Shutting down closes the file, spans ended after are dropped
+	assert.NoError(t, shutdown(context.Background()))
+	_, span = otel.Tracer(Name).Start(context.Background(), "late")
+	span.End()
+	after, _ := ioutil.ReadFile(file)
+	assert.Equal(t, contents, after)
+
+	_, err = Setup(Config{Exporter: "jaeger", IDFormat: IDFormatW3C})
+	assert.Error(t, err)
+	_, err = Setup(Config{Exporter: ExporterStdout, IDFormat: "uuid"})
+	assert.Error(t, err)
+}
+
+func TestConfigFromEnv(t *testing.T) {
+	os.Unsetenv("TRACE_EXPORTER")
+	os.Unsetenv("TRACE_ID_FORMAT")
+	os.Setenv("AWS_LAMBDA_FUNCTION_NAME", "users")
+	defer os.Unsetenv("AWS_LAMBDA_FUNCTION_NAME")
+
+	assert.Equal(t, Config{
+		Service:  "users",
+		Exporter: ExporterNone,
+		File:     "traces.jsonl",
+		IDFormat: IDFormatXRay,
+	}, ConfigFromEnv("users"))
+}
@@ -0,0 +1,148 @@
+// Package validation checks structs against their validate tags, describing
+// each problem by its JSON field name, in the language the client asked for.
+package validation
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/EwanValentine/serverless-api-example/pkg/apperrors"
+	"github.com/go-playground/locales/en"
+	"github.com/go-playground/locales/fr"
+	"github.com/go-playground/locales/nl"
+	ut "github.com/go-playground/universal-translator"
+	"gopkg.in/go-playground/validator.v9"
+	en_translations "gopkg.in/go-playground/validator.v9/translations/en"
+	fr_translations "gopkg.in/go-playground/validator.v9/translations/fr"
+	nl_translations "gopkg.in/go-playground/validator.v9/translations/nl"
+)
+
+var (
+	// validate is shared, as it caches what it learns about each struct
+	validate *validator.Validate
+
+	translators *ut.UniversalTranslator
+)
+
+func init() {
+	validate = validator.New()
+	validate.RegisterTagNameFunc(jsonName)
+
+	english := en.New()
+	translators = ut.New(english, english, fr.New(), nl.New())
+
+	register := map[string]func(*validator.Validate, ut.Translator) error{
+		"en": en_translations.RegisterDefaultTranslations,
+		"fr": fr_translations.RegisterDefaultTranslations,
+		"nl": nl_translations.RegisterDefaultTranslations,
+	}
+	for locale, fn := range register {
+		trans, _ := translators.GetTranslator(locale)
+		if err := fn(validate, trans); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// jsonName names fields the way clients see them
+func jsonName(field reflect.StructField) string {
+	name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+	if name == "-" {
+		return ""
+	}
+	if name == "" {
+		return field.Name
+	}
+	return name
+}
+
+type localeKey struct{}
+
+// WithLocale sets the languages validation messages are written in,
+// from an Accept-Language header. English is used when none match.
+func WithLocale(ctx context.Context, acceptLanguage string) context.Context {
+	return context.WithValue(ctx, localeKey{}, acceptLanguage)
+}
+
+func translator(ctx context.Context) ut.Translator {
+	acceptLanguage, _ := ctx.Value(localeKey{}).(string)
+	trans, _ := translators.FindTranslator(locales(acceptLanguage)...)
+	return trans
+}
+
+// locales in an Accept-Language header, most preferred first, e.g.
+// "fr-CH, fr;q=0.9, en;q=0.8" gives fr_CH, fr, fr, en. Each region is
+// followed by its base language, as we mostly only have those.
+func locales(acceptLanguage string) []string {
+	type weighted struct {
+		locale string
+		q      float64
+	}
+
+	ranges := make([]weighted, 0)
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		params := strings.Split(strings.TrimSpace(part), ";")
+		locale := strings.Replace(strings.TrimSpace(params[0]), "-", "_", -1)
+		if locale == "" || locale == "*" {
+			continue
+		}
+
+		q := 1.0
+		for _, param := range params[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if v, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					q = v
+				}
+			}
+		}
+		ranges = append(ranges, weighted{locale, q})
+	}
+
+	sort.SliceStable(ranges, func(i, j int) bool {
+		return ranges[i].q > ranges[j].q
+	})
+
+	result := make([]string, 0, len(ranges)*2)
+	for _, r := range ranges {
+		result = append(result, r.locale)
+		if i := strings.Index(r.locale, "_"); i > 0 {
+			result = append(result, r.locale[:i])
+		}
+	}
+	return result
+}
+
+// Struct validates s, returning a Validation error listing every
+// field which failed, in the language set on the context
+func Struct(ctx context.Context, s interface{}) error {
+	err := validate.Struct(s)
+	if err == nil {
+		return nil
+	}
+
+	validationErrors, ok := err.(validator.ValidationErrors)
+	if !ok {
+		return err
+	}
+
+	trans := translator(ctx)
+	fields := make([]apperrors.FieldError, 0, len(validationErrors))
+	for _, fe := range validationErrors {
+		fields = append(fields, apperrors.FieldError{
+			Field:   fe.Field(),
+			Rule:    fe.Tag(),
+			Message: fe.Translate(trans),
+		})
+	}
+
+	return &apperrors.Error{
+		Code:    apperrors.Validation,
+		Message: "validation failed",
+		Fields:  fields,
+		Err:     err,
+	}
+}
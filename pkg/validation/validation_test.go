@@ -0,0 +1,50 @@
+package validation
+
+import (
+	"context"
+	"testing"
+
+	"github.com/EwanValentine/serverless-api-example/pkg/apperrors"
+	"github.com/stretchr/testify/assert"
+)
+
+type person struct {
+	Email string `json:"email" validate:"required,email"`
+	Name  string `json:"name,omitempty" validate:"required"`
+}
+
+func fields(t *testing.T, err error) []apperrors.FieldError {
+	e, ok := apperrors.As(err)
+	if !assert.True(t, ok) {
+		return nil
+	}
+	assert.Equal(t, apperrors.Validation, e.Code)
+	return e.Fields
+}
+
+func TestStructUsesJSONNames(t *testing.T) {
+	err := Struct(context.Background(), &person{Email: "nope"})
+	assert.Equal(t, []apperrors.FieldError{
+		{Field: "email", Rule: "email", Message: "email must be a valid email address"},
+		{Field: "name", Rule: "required", Message: "name is a required field"},
+	}, fields(t, err))
+
+	assert.NoError(t, Struct(context.Background(), &person{Email: "test@test.com", Name: "Ewan"}))
+}
+
+func TestStructTranslatesMessages(t *testing.T) {
+	ctx := WithLocale(context.Background(), "de-DE, fr-CH;q=0.9, en;q=0.8")
+	err := Struct(ctx, &person{Email: "test@test.com"})
+	assert.Equal(t, "name est un champ obligatoire", fields(t, err)[0].Message)
+
+	// Languages we don't have fall back to English
+	ctx = WithLocale(context.Background(), "de")
+	err = Struct(ctx, &person{Email: "test@test.com"})
+	assert.Equal(t, "name is a required field", fields(t, err)[0].Message)
+}
+
+func TestLocales(t *testing.T) {
+	assert.Equal(t, []string{"fr_CH", "fr", "en"}, locales("en;q=0.5, fr-CH"))
+	assert.Empty(t, locales(""))
+	assert.Empty(t, locales("*"))
+}
@@ -0,0 +1,18 @@
+package users
+
+import (
+	"time"
+)
+
+// Clock tells the usecase the time, so
+// tests can control timestamps
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+// Now is the current time, in UTC
+func (systemClock) Now() time.Time {
+	return time.Now().UTC()
+}
@@ -0,0 +1,27 @@
+package users
+
+import (
+	"encoding/base64"
+)
+
+const (
+	// DefaultPageSize is used when GetAll is called without a limit
+	DefaultPageSize = 25
+
+	// MaxPageSize caps how many users a single page can hold
+	MaxPageSize = 100
+)
+
+// encodeCursor hides the key of the last user in a page
+// behind an opaque, URL safe token.
+func encodeCursor(id string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(id))
+}
+
+func decodeCursor(cursor string) (string, error) {
+	id, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil || len(id) == 0 {
+		return "", ErrInvalidCursor
+	}
+	return string(id), nil
+}
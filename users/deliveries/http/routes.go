@@ -1,141 +1,88 @@
 package http
 
 import (
-	"context"
-	"encoding/json"
+	"io/ioutil"
 	"log"
 	"net/http"
-	"time"
 
+	"github.com/EwanValentine/serverless-api-example/pkg/api"
+	"github.com/EwanValentine/serverless-api-example/pkg/logging"
+	"github.com/EwanValentine/serverless-api-example/pkg/metrics"
+	"github.com/EwanValentine/serverless-api-example/pkg/tracing"
 	"github.com/EwanValentine/serverless-api-example/users"
-	"github.com/gorilla/mux"
+	"github.com/EwanValentine/serverless-api-example/users/deliveries/rest"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
 )
 
-const fiveSecondsTimeout = time.Second * 5
-
-type delivery struct {
-	usecase users.UserService
-}
-
-func writeErr(w http.ResponseWriter, err error) {
-	w.WriteHeader(http.StatusInternalServerError)
-	w.Write([]byte(err.Error()))
-}
-
-func (d *delivery) Get(w http.ResponseWriter, r *http.Request) {
-	ctx, cancel := context.WithTimeout(context.Background(), fiveSecondsTimeout)
-	defer cancel()
-
-	vars := mux.Vars(r)
-	id := vars["id"]
-
-	user, err := d.usecase.Get(ctx, id)
-	if err != nil {
-		writeErr(w, err)
-		return
-	}
-
-	data, err := json.Marshal(user)
-	if err != nil {
-		writeErr(w, err)
-		return
-	}
-
-	w.WriteHeader(http.StatusOK)
-	w.Write(data)
-}
-
-func (d *delivery) GetAll(w http.ResponseWriter, r *http.Request) {
-	ctx, cancel := context.WithTimeout(context.Background(), fiveSecondsTimeout)
-	defer cancel()
-
-	users, err := d.usecase.GetAll(ctx)
-	if err != nil {
-		writeErr(w, err)
-		return
+// request turns an HTTP request into a transport neutral one
+func request(r *http.Request, body []byte) api.Request {
+	query := make(map[string]string)
+	for key, values := range r.URL.Query() {
+		query[key] = values[0]
 	}
 
-	data, err := json.Marshal(users)
-	if err != nil {
-		writeErr(w, err)
-		return
+	headers := make(map[string]string)
+	for key, values := range r.Header {
+		headers[key] = values[0]
 	}
 
-	w.WriteHeader(http.StatusOK)
-	w.Write(data)
-}
-
-func (d *delivery) Update(w http.ResponseWriter, r *http.Request) {
-	ctx, cancel := context.WithTimeout(context.Background(), fiveSecondsTimeout)
-	defer cancel()
-
-	decoder := json.NewDecoder(r.Body)
-	user := &users.UpdateUser{}
-	if err := decoder.Decode(&user); err != nil {
-		writeErr(w, err)
-		return
-	}
-
-	vars := mux.Vars(r)
-	id := vars["id"]
-
-	if err := d.usecase.Update(ctx, id, user); err != nil {
-		writeErr(w, err)
-		return
+	return api.Request{
+		Method:    r.Method,
+		Path:      r.URL.Path,
+		Query:     query,
+		Headers:   headers,
+		Body:      body,
+		RequestID: r.Header.Get("X-Request-Id"),
 	}
 }
 
-func (d *delivery) Create(w http.ResponseWriter, r *http.Request) {
-	ctx, cancel := context.WithTimeout(context.Background(), fiveSecondsTimeout)
-	defer cancel()
-
-	decoder := json.NewDecoder(r.Body)
-	user := &users.User{}
-	if err := decoder.Decode(&user); err != nil {
-		writeErr(w, err)
-		return
+func write(w http.ResponseWriter, res api.Response) {
+	for key, value := range res.Headers {
+		w.Header().Set(key, value)
 	}
-
-	if err := d.usecase.Create(ctx, user); err != nil {
-		writeErr(w, err)
-		return
+	for _, cookie := range res.Cookies {
+		w.Header().Add("Set-Cookie", cookie)
 	}
-
-	w.WriteHeader(http.StatusCreated)
-	w.Write([]byte("Ok"))
+	w.WriteHeader(res.Status)
+	w.Write(res.Body)
 }
 
-func (d *delivery) Delete(w http.ResponseWriter, r *http.Request) {
-	ctx, cancel := context.WithTimeout(context.Background(), fiveSecondsTimeout)
-	defer cancel()
-
-	vars := mux.Vars(r)
-	id := vars["id"]
-
-	if err := d.usecase.Delete(ctx, id); err != nil {
-		writeErr(w, err)
-		return
-	}
-
-	w.WriteHeader(http.StatusNoContent)
-	w.Write([]byte("Deleted"))
+// Handler serves the routes of an API over HTTP, they're
+// matched the same way as they are in the lambda delivery. Requests
+// are cancelled when the client goes away, or the server shuts down.
+func Handler(routes []api.Route) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			write(w, api.Fail(request(r, nil), users.ErrMalformed))
+			return
+		}
+
+		ctx := r.Context()
+		if trace := r.Header.Get("X-Amzn-Trace-Id"); trace != "" {
+			ctx = logging.With(ctx, zap.String("traceId", logging.TraceID(trace)))
+		}
+
+		write(w, api.Serve(ctx, routes, request(r, body)))
+	})
 }
 
-// Routes -
-func Routes() (*mux.Router, error) {
+// Routes of the users API, with timeouts configured by TIMEOUTS,
+// measured by metrics registered with Prometheus' default registry,
+// and traced, continuing the trace the caller's traceparent names
+func Routes() (http.Handler, error) {
 	usecase, err := users.Init(true)
 	if err != nil {
 		log.Panic(err)
 	}
 
-	delivery := &delivery{usecase}
-
-	r := mux.NewRouter()
-	r.HandleFunc("/users", delivery.Create).Methods("POST")
-	r.HandleFunc("/users", delivery.GetAll).Methods("GET")
-	r.HandleFunc("/users/{id}", delivery.Get).Methods("GET")
-	r.HandleFunc("/users/{id}", delivery.Update).Methods("PUT")
-	r.HandleFunc("/users/{id}", delivery.Delete).Methods("DELETE")
+	timeouts, err := api.TimeoutsFromEnv()
+	if err != nil {
+		return nil, err
+	}
 
-	return r, nil
+	routes := api.WithTimeouts(rest.Routes(usecase), timeouts)
+	routes = metrics.NewHTTP(prometheus.DefaultRegisterer).Routes(routes)
+	return Handler(tracing.Routes(routes)), nil
 }
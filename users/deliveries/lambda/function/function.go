@@ -0,0 +1,40 @@
+// Package function builds the users lambda function, exactly as it's
+// given to lambda.Start, so it can be run locally too.
+package function
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/EwanValentine/serverless-api-example/pkg/api"
+	"github.com/EwanValentine/serverless-api-example/pkg/helpers"
+	"github.com/EwanValentine/serverless-api-example/pkg/tracing"
+	"github.com/EwanValentine/serverless-api-example/users"
+	"github.com/EwanValentine/serverless-api-example/users/deliveries/rest"
+)
+
+// Function is a lambda handler for any of the events helpers.Handler accepts
+type Function func(ctx context.Context, payload json.RawMessage) (interface{}, error)
+
+// New users function, configured by users.Init, with
+// timeouts for each operation configured by TIMEOUTS.
+// Outside of integration, the metrics of each request
+// are logged for CloudWatch, see users.LambdaMetrics, and
+// every request is traced, see tracing.Routes.
+func New(integration bool) (Function, error) {
+	usecase, err := users.Init(integration)
+	if err != nil {
+		return nil, err
+	}
+
+	timeouts, err := api.TimeoutsFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	routes := api.WithTimeouts(rest.Routes(usecase), timeouts)
+	if integration == false {
+		routes = users.LambdaMetrics.Routes(routes)
+	}
+	return helpers.Handler(tracing.Routes(routes)), nil
+}
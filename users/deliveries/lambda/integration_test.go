@@ -3,8 +3,11 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"github.com/EwanValentine/serverless-api-example/pkg/api"
+	"github.com/EwanValentine/serverless-api-example/pkg/apperrors"
 	"github.com/EwanValentine/serverless-api-example/pkg/helpers"
 	"github.com/EwanValentine/serverless-api-example/users"
+	"github.com/EwanValentine/serverless-api-example/users/deliveries/rest"
 	"github.com/stretchr/testify/assert"
 	"log"
 	"net/http"
@@ -18,28 +21,41 @@ var (
 	updatedUser = `{ "name": "Updated User", "email": "test@test.com", "age": 30 }`
 )
 
-func setup() *handler {
-	os.Setenv("TABLE_NAME", "example-users-integration")
-	usecase, err := users.Init(true)
-	if err != nil {
-		log.Panic(err)
+// usecase is shared between tests, as they build on each other's data.
+// Tests run against the in-memory datastore unless DATASTORE is set,
+// e.g. DATASTORE=dynamodb to hit the integration table.
+var usecase users.UserService
+
+func setup() []api.Route {
+	if usecase == nil {
+		os.Setenv("TABLE_NAME", "example-users-integration")
+		if os.Getenv("DATASTORE") == "" {
+			os.Setenv("DATASTORE", users.DatastoreMemory)
+		}
+
+		var err error
+		usecase, err = users.Init(true)
+		if err != nil {
+			log.Panic(err)
+		}
 	}
 
-	h := &handler{usecase}
-	return h
+	return rest.Routes(usecase)
 }
 
 func clear() {
-	os.Setenv("TABLE_NAME", "example-users-integration")
-	usecase, err := users.Init(true)
-	if err != nil {
-		log.Panic(err)
-	}
-
+	setup()
 	ctx := context.Background()
-	users, _ := usecase.GetAll(ctx)
-	for _, user := range users {
-		go usecase.Delete(ctx, user.ID)
+	cursor := ""
+	for {
+		page, next, _ := usecase.GetAll(ctx, users.MaxPageSize, cursor)
+		for _, user := range page {
+			go usecase.Delete(ctx, user.ID, 0)
+		}
+		if next == "" {
+			return
+		}
+		cursor = next
 	}
 }
 
@@ -47,83 +63,333 @@ func TestCanCreate(t *testing.T) {
 	ctx := context.Background()
 	user := &users.User{}
 	clear()
-	h := setup()
+	routes := setup()
 	req := helpers.Request{
 		HTTPMethod: "POST",
+		Resource:   "/users",
 		Body:       validUser,
 	}
-	res, err := helpers.Router(h)(ctx, req)
+	res, err := helpers.Router(routes)(ctx, req)
 	assert.NoError(t, err)
 
 	err = json.Unmarshal([]byte(res.Body), &user)
 	assert.NoError(t, err)
 	assert.Equal(t, http.StatusCreated, res.StatusCode)
 	assert.NotNil(t, user.ID)
+	assert.False(t, user.CreatedAt.IsZero())
+	assert.Equal(t, user.CreatedAt, user.UpdatedAt)
 	id = user.ID
 }
 
 func TestCanGetAllUsers(t *testing.T) {
 	ctx := context.Background()
-	u := []*users.User{}
-	h := setup()
+	u := &users.Page{}
+	routes := setup()
 	req := helpers.Request{
 		HTTPMethod: "GET",
+		Resource:   "/users",
 	}
-	res, err := helpers.Router(h)(ctx, req)
+	res, err := helpers.Router(routes)(ctx, req)
 	assert.NoError(t, err)
 	err = json.Unmarshal([]byte(res.Body), &u)
 	assert.NoError(t, err)
-	assert.NotNil(t, u)
-	assert.Equal(t, "Test User", u[0].Name)
+	assert.NotNil(t, u.Users)
+	assert.Equal(t, "Test User", u.Users[0].Name)
+}
+
+func TestGetAllUsersInvalidCursor(t *testing.T) {
+	ctx := context.Background()
+	routes := setup()
+	req := helpers.Request{
+		HTTPMethod: "GET",
+		Resource:   "/users",
+		QueryStringParameters: map[string]string{
+			"cursor": "not a cursor!",
+		},
+	}
+	res, err := helpers.Router(routes)(ctx, req)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, res.StatusCode)
 }
 
 func TestCanGetUser(t *testing.T) {
 	ctx := context.Background()
 	u := &users.User{}
-	h := setup()
+	routes := setup()
 	req := helpers.Request{
 		HTTPMethod: "GET",
+		Resource:   "/users/{id}",
 		PathParameters: map[string]string{
 			"id": id,
 		},
 	}
-	res, err := helpers.Router(h)(ctx, req)
+	res, err := helpers.Router(routes)(ctx, req)
 	err = json.Unmarshal([]byte(res.Body), &u)
 	assert.NoError(t, err)
 	assert.Equal(t, "Test User", u.Name)
 }
 
+func TestCanGetUserByEmail(t *testing.T) {
+	ctx := context.Background()
+	u := &users.User{}
+	routes := setup()
+	req := helpers.Request{
+		HTTPMethod: "GET",
+		Resource:   "/users",
+		QueryStringParameters: map[string]string{
+			"email": "test@test.com",
+		},
+	}
+	res, err := helpers.Router(routes)(ctx, req)
+	assert.NoError(t, err)
+	err = json.Unmarshal([]byte(res.Body), &u)
+	assert.NoError(t, err)
+	assert.Equal(t, id, u.ID)
+}
+
+func TestCreateDuplicateEmailIsConflict(t *testing.T) {
+	ctx := context.Background()
+	routes := setup()
+	req := helpers.Request{
+		HTTPMethod: "POST",
+		Resource:   "/users",
+		Path:       "/users",
+		Body:       validUser,
+	}
+	req.RequestContext.RequestID = "abc123"
+	res, err := helpers.Router(routes)(ctx, req)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusConflict, res.StatusCode)
+	assert.Equal(t, apperrors.ContentType, res.Headers["Content-Type"])
+
+	problem := apperrors.Problem{}
+	err = json.Unmarshal([]byte(res.Body), &problem)
+	assert.NoError(t, err)
+	assert.Equal(t, apperrors.Problem{
+		Type:      "/problems/conflict",
+		Title:     "Conflict",
+		Status:    http.StatusConflict,
+		Detail:    "email already in use",
+		Instance:  "/users",
+		Code:      apperrors.Conflict,
+		RequestID: "abc123",
+	}, problem)
+}
+
+func TestCreateMalformedUserIsBadRequest(t *testing.T) {
+	ctx := context.Background()
+	routes := setup()
+	for _, body := range []string{`{ "name": `, `{ "name": "Test User", "email": "nope", "age": 30 }`} {
+		req := helpers.Request{
+			HTTPMethod: "POST",
+			Resource:   "/users",
+			Body:       body,
+		}
+		res, err := helpers.Router(routes)(ctx, req)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+	}
+}
+
+func TestCreateInvalidUserListsFields(t *testing.T) {
+	ctx := context.Background()
+	routes := setup()
+	req := helpers.Request{
+		HTTPMethod: "POST",
+		Resource:   "/users",
+		Headers:    map[string]string{"accept-language": "fr"},
+		Body:       `{ "name": "Test User", "email": "nope", "age": 30 }`,
+	}
+	res, err := helpers.Router(routes)(ctx, req)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+
+	problem := apperrors.Problem{}
+	err = json.Unmarshal([]byte(res.Body), &problem)
+	assert.NoError(t, err)
+	assert.Equal(t, []apperrors.FieldError{{
+		Field:   "email",
+		Rule:    "email",
+		Message: "email doit être une adresse email valide",
+	}}, problem.Errors)
+}
+
 func TestCanUpdateUser(t *testing.T) {
 	ctx := context.Background()
-	r := map[string]interface{}{}
-	h := setup()
+	u := &users.User{}
+	routes := setup()
 	req := helpers.Request{
 		HTTPMethod: "PUT",
+		Resource:   "/users/{id}",
 		PathParameters: map[string]string{
 			"id": id,
 		},
 		Body: updatedUser,
 	}
-	res, err := helpers.Router(h)(ctx, req)
-	err = json.Unmarshal([]byte(res.Body), &r)
+	res, err := helpers.Router(routes)(ctx, req)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	err = json.Unmarshal([]byte(res.Body), &u)
+	assert.NoError(t, err)
+	assert.Equal(t, "Updated User", u.Name)
+	assert.Equal(t, helpers.ETag(u.Version), res.Headers["ETag"])
+}
+
+func TestCanPatchUser(t *testing.T) {
+	ctx := context.Background()
+	u := &users.User{}
+	routes := setup()
+	req := helpers.Request{
+		HTTPMethod: "PATCH",
+		Resource:   "/users/{id}",
+		PathParameters: map[string]string{
+			"id": id,
+		},
+		Body: `{ "age": 31 }`,
+	}
+	_, err := helpers.Router(routes)(ctx, req)
+	assert.NoError(t, err)
+
+	req = helpers.Request{
+		HTTPMethod: "GET",
+		Resource:   "/users/{id}",
+		PathParameters: map[string]string{
+			"id": id,
+		},
+	}
+	res, err := helpers.Router(routes)(ctx, req)
+	err = json.Unmarshal([]byte(res.Body), &u)
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(31), u.Age)
+	assert.Equal(t, "Updated User", u.Name)
+}
+
+func TestStaleIfMatchIsPreconditionFailed(t *testing.T) {
+	ctx := context.Background()
+	routes := setup()
+	req := helpers.Request{
+		HTTPMethod: "GET",
+		Resource:   "/users/{id}",
+		PathParameters: map[string]string{
+			"id": id,
+		},
+	}
+	res, err := helpers.Router(routes)(ctx, req)
+	assert.NoError(t, err)
+	etag := res.Headers["ETag"]
+	assert.NotEqual(t, helpers.ETag(1), etag)
+
+	req = helpers.Request{
+		HTTPMethod: "PATCH",
+		Resource:   "/users/{id}",
+		PathParameters: map[string]string{
+			"id": id,
+		},
+		Headers: map[string]string{
+			"if-match": helpers.ETag(1),
+		},
+		Body: `{ "age": 32 }`,
+	}
+	res, err = helpers.Router(routes)(ctx, req)
 	assert.NoError(t, err)
-	assert.Equal(t, true, r["success"])
+	assert.Equal(t, http.StatusPreconditionFailed, res.StatusCode)
+
+	req.Headers["if-match"] = etag
+	res, err = helpers.Router(routes)(ctx, req)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.NotEqual(t, etag, res.Headers["ETag"])
 }
 
 func TestCanDeleteUser(t *testing.T) {
 	ctx := context.Background()
-	r := map[string]interface{}{}
-	h := setup()
+	routes := setup()
 	req := helpers.Request{
 		HTTPMethod: "DELETE",
+		Resource:   "/users/{id}",
 		PathParameters: map[string]string{
 			"id": id,
 		},
 	}
-	res, err := helpers.Router(h)(ctx, req)
-	err = json.Unmarshal([]byte(res.Body), &r)
+	res, err := helpers.Router(routes)(ctx, req)
 	assert.NoError(t, err)
 	assert.Equal(t, http.StatusNoContent, res.StatusCode)
-	expected := true
-	assert.Equal(t, expected, r["success"])
+	assert.Empty(t, res.Body)
+
+	req = helpers.Request{
+		HTTPMethod: "GET",
+		Resource:   "/users/{id}",
+		PathParameters: map[string]string{
+			"id": id,
+		},
+	}
+	res, err = helpers.Router(routes)(ctx, req)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, res.StatusCode)
+}
+
+func TestGetUnknownUserIsNotFound(t *testing.T) {
+	ctx := context.Background()
+	routes := setup()
+	req := helpers.Request{
+		HTTPMethod: "GET",
+		Resource:   "/users/{id}",
+		PathParameters: map[string]string{
+			"id": "does-not-exist",
+		},
+	}
+	res, err := helpers.Router(routes)(ctx, req)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, res.StatusCode)
+}
+
+func TestUpdateUnknownUserIsNotFound(t *testing.T) {
+	ctx := context.Background()
+	routes := setup()
+	req := helpers.Request{
+		HTTPMethod: "PUT",
+		Resource:   "/users/{id}",
+		PathParameters: map[string]string{
+			"id": "does-not-exist",
+		},
+		Body: updatedUser,
+	}
+	res, err := helpers.Router(routes)(ctx, req)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, res.StatusCode)
+}
+
+func TestDeleteUnknownUserIsNotFound(t *testing.T) {
+	ctx := context.Background()
+	routes := setup()
+	req := helpers.Request{
+		HTTPMethod: "DELETE",
+		Resource:   "/users/{id}",
+		PathParameters: map[string]string{
+			"id": "does-not-exist",
+		},
+	}
+	res, err := helpers.Router(routes)(ctx, req)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, res.StatusCode)
+}
+
+func TestCanRestoreUser(t *testing.T) {
+	ctx := context.Background()
+	u := &users.User{}
+	routes := setup()
+	req := helpers.Request{
+		HTTPMethod: "POST",
+		Resource:   "/users/{id}/restore",
+		PathParameters: map[string]string{
+			"id": id,
+		},
+	}
+	res, err := helpers.Router(routes)(ctx, req)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	err = json.Unmarshal([]byte(res.Body), &u)
+	assert.NoError(t, err)
+	assert.Equal(t, id, u.ID)
+	assert.Nil(t, u.DeletedAt)
 }
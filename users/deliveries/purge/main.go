@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"github.com/EwanValentine/serverless-api-example/pkg/tracing"
+	"github.com/EwanValentine/serverless-api-example/users"
+	"github.com/aws/aws-lambda-go/lambda"
+	"log"
+)
+
+type result struct {
+	Purged int `json:"purged"`
+}
+
+// handler purges deleted users which are past their retention,
+// it runs on a schedule, see serverless.yml.
+type handler struct {
+	usecase users.UserService
+}
+
+// Purge deleted users
+func (h *handler) Purge(ctx context.Context) (result, error) {
+	purged, err := h.usecase.Purge(ctx)
+	return result{Purged: purged}, err
+}
+
+func main() {
+	shutdown, err := tracing.Setup(tracing.ConfigFromEnv("users-purge"))
+	if err != nil {
+		log.Panic(err)
+	}
+
+	usecase, err := users.Init(false)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	h := &handler{usecase}
+	lambda.StartWithOptions(h.Purge, lambda.WithEnableSIGTERM(func() {
+		shutdown(context.Background())
+	}))
+}
@@ -0,0 +1,200 @@
+package rest_test
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/EwanValentine/serverless-api-example/pkg/helpers"
+	"github.com/EwanValentine/serverless-api-example/users"
+	delivery "github.com/EwanValentine/serverless-api-example/users/deliveries/http"
+	"github.com/EwanValentine/serverless-api-example/users/deliveries/rest"
+	"github.com/stretchr/testify/assert"
+)
+
+type fixedClock struct{}
+
+func (fixedClock) Now() time.Time {
+	return time.Date(2019, 9, 1, 12, 0, 0, 0, time.UTC)
+}
+
+// call is a request in the contract, made to a route by its pattern,
+// or through a proxy resource, which lambda is told of instead
+type call struct {
+	method   string
+	resource string
+	proxy    string
+	id       string
+	query    map[string]string
+	headers  map[string]string
+	body     string
+}
+
+func (c call) path() string {
+	return strings.Replace(c.resource, "{id}", c.id, 1)
+}
+
+// result is the part of a response both deliveries must agree on
+type result struct {
+	Status      int
+	ContentType string
+	ETag        string
+	Allow       string
+	Body        string
+}
+
+var contract = []call{
+	{method: "POST", resource: "/users", body: `{ "name": "Test User", "email": "test@test.com", "age": 30 }`},
+	{method: "POST", resource: "/users", body: `{ "name": "Test User", "email": "test@test.com", "age": 30 }`},
+	{method: "POST", resource: "/users", body: `{ "name": `},
+	{method: "POST", resource: "/users", body: `{ "name": "Test User", "email": "nope" }`, headers: map[string]string{"Accept-Language": "fr"}},
+	{method: "GET", resource: "/users"},
+	{method: "GET", resource: "/users", query: map[string]string{"limit": "nope"}},
+	{method: "GET", resource: "/users", query: map[string]string{"cursor": "not a cursor!"}},
+	{method: "GET", resource: "/users", query: map[string]string{"email": "TEST@test.com"}},
+	{method: "GET", resource: "/users/{id}", id: "abc123"},
+	{method: "GET", resource: "/users/{id}", id: "unknown"},
+	{method: "PATCH", resource: "/users/{id}", id: "abc123", body: `{ "age": 31 }`, headers: map[string]string{"If-Match": `"1"`}},
+	{method: "PATCH", resource: "/users/{id}", id: "abc123", body: `{ "age": 32 }`, headers: map[string]string{"If-Match": `"1"`}},
+	{method: "PUT", resource: "/users/{id}", id: "abc123", body: `{ "name": "Updated User" }`},
+	{method: "DELETE", resource: "/users/{id}", id: "abc123"},
+	{method: "GET", resource: "/users/{id}", id: "abc123"},
+	{method: "DELETE", resource: "/users/{id}", id: "abc123"},
+	{method: "POST", resource: "/users/{id}/restore", id: "abc123"},
+	{method: "PUT", resource: "/users"},
+	{method: "GET", resource: "/users/{id}/unknown", id: "abc123"},
+	{method: "GET", resource: "/users/{id}", proxy: "/{proxy+}", id: "abc123"},
+	{method: "PATCH", resource: "/users/{id}", proxy: "/users/{proxy+}", id: "abc123", body: `{ "age": 33 }`},
+	{method: "PUT", resource: "/users", proxy: "/{proxy+}"},
+	{method: "GET", resource: "/users/{id}/unknown", proxy: "/users/{proxy+}", id: "abc123"},
+	{method: "DELETE", resource: "/users/{id}", id: "abc123", headers: map[string]string{"If-Match": `"5"`}},
+	{method: "DELETE", resource: "/users/{id}", id: "abc123", headers: map[string]string{"If-Match": `"6"`}},
+	{method: "POST", resource: "/users/{id}/restore", id: "abc123", headers: map[string]string{"If-Match": `"6"`}},
+	{method: "POST", resource: "/users/{id}/restore", id: "abc123", headers: map[string]string{"If-Match": `"7"`}},
+	{method: "POST", resource: "/users", body: `null`},
+	{method: "PATCH", resource: "/users/{id}", id: "abc123", body: `null`, headers: map[string]string{"If-Match": `"8"`}},
+}
+
+func newUsecase() users.UserService {
+	return &users.Usecase{
+		Repository: users.NewMemoryRepository(),
+		Clock:      fixedClock{},
+		IDs:        users.IDGeneratorFunc(func() string { return "abc123" }),
+	}
+}
+
+// overHTTP and overLambda make calls through each delivery,
+// each with their own, deterministic, users
+func overHTTP() func(c call) result {
+	handler := delivery.Handler(rest.Routes(newUsecase()))
+	return func(c call) result {
+		query := url.Values{}
+		for key, value := range c.query {
+			query.Set(key, value)
+		}
+
+		req := httptest.NewRequest(c.method, c.path()+"?"+query.Encode(), bytes.NewBufferString(c.body))
+		for key, value := range c.headers {
+			req.Header.Set(key, value)
+		}
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return result{
+			Status:      w.Code,
+			ContentType: w.Header().Get("Content-Type"),
+			ETag:        w.Header().Get("ETag"),
+			Allow:       w.Header().Get("Allow"),
+			Body:        w.Body.String(),
+		}
+	}
+}
+
+func overLambda() func(c call) result {
+	router := helpers.Router(rest.Routes(newUsecase()))
+	return func(c call) result {
+		req := helpers.Request{
+			HTTPMethod:            c.method,
+			Resource:              c.resource,
+			Path:                  c.path(),
+			QueryStringParameters: c.query,
+			Headers:               c.headers,
+			Body:                  c.body,
+		}
+		if c.id != "" {
+			req.PathParameters = map[string]string{"id": c.id}
+		}
+		if c.proxy != "" {
+			req.Resource = c.proxy
+			req.PathParameters = map[string]string{"proxy": strings.TrimPrefix(c.path(), strings.TrimSuffix(c.proxy, "{proxy+}"))}
+		}
+
+		res, err := router(context.Background(), req)
+		if err != nil {
+			return result{Body: err.Error()}
+		}
+		return result{
+			Status:      res.StatusCode,
+			ContentType: res.Headers["Content-Type"],
+			ETag:        res.Headers["ETag"],
+			Allow:       res.Headers["Allow"],
+			Body:        res.Body,
+		}
+	}
+}
+
+// TestDeliveriesHonourTheSameContract makes the same calls through
+// each delivery, which must respond in exactly the same way.
+func TestDeliveriesHonourTheSameContract(t *testing.T) {
+	viaHTTP, viaLambda := overHTTP(), overLambda()
+	for _, c := range contract {
+		expected := viaHTTP(c)
+		assert.Equal(t, expected, viaLambda(c), "%s %s", c.method, c.path())
+		assert.NotZero(t, expected.Status, "%s %s", c.method, c.path())
+	}
+}
+
+func TestContractResponses(t *testing.T) {
+	do := overLambda()
+	statuses := make([]int, 0, len(contract))
+	for _, c := range contract {
+		statuses = append(statuses, do(c).Status)
+	}
+
+	assert.Equal(t, []int{
+		http.StatusCreated,
+		http.StatusConflict,
+		http.StatusBadRequest,
+		http.StatusBadRequest,
+		http.StatusOK,
+		http.StatusBadRequest,
+		http.StatusBadRequest,
+		http.StatusOK,
+		http.StatusOK,
+		http.StatusNotFound,
+		http.StatusOK,
+		http.StatusPreconditionFailed,
+		http.StatusOK,
+		http.StatusNoContent,
+		http.StatusNotFound,
+		http.StatusNotFound,
+		http.StatusOK,
+		http.StatusMethodNotAllowed,
+		http.StatusNotFound,
+		http.StatusOK,
+		http.StatusOK,
+		http.StatusMethodNotAllowed,
+		http.StatusNotFound,
+		http.StatusPreconditionFailed,
+		http.StatusNoContent,
+		http.StatusPreconditionFailed,
+		http.StatusOK,
+		http.StatusBadRequest,
+		http.StatusOK,
+	}, statuses)
+}
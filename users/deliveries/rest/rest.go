@@ -0,0 +1,168 @@
+// Package rest is the users API, independent of how it's served.
+// The http and lambda deliveries adapt its routes to their transport.
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/EwanValentine/serverless-api-example/pkg/api"
+	"github.com/EwanValentine/serverless-api-example/pkg/helpers"
+	"github.com/EwanValentine/serverless-api-example/pkg/validation"
+	"github.com/EwanValentine/serverless-api-example/users"
+)
+
+type handler struct {
+	usecase users.UserService
+}
+
+// Routes of the users API
+func Routes(usecase users.UserService) []api.Route {
+	h := &handler{usecase}
+	return []api.Route{
+		{Method: http.MethodPost, Path: "/users", Operation: "create", Handler: h.Create},
+		{Method: http.MethodGet, Path: "/users", Operation: "getAll", Handler: h.GetAll},
+		{Method: http.MethodGet, Path: "/users/{id}", Operation: "get", Handler: h.Get},
+		{Method: http.MethodPut, Path: "/users/{id}", Operation: "update", Handler: h.Update},
+		{Method: http.MethodPatch, Path: "/users/{id}", Operation: "update", Handler: h.Update},
+		{Method: http.MethodDelete, Path: "/users/{id}", Operation: "delete", Handler: h.Delete},
+		{Method: http.MethodPost, Path: "/users/{id}/restore", Operation: "restore", Handler: h.Restore},
+	}
+}
+
+// nextLink points clients at the page following the current one
+func nextLink(limit int, cursor string) string {
+	if cursor == "" {
+		return ""
+	}
+
+	query := url.Values{}
+	query.Set("cursor", cursor)
+	if limit > 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+	return "/users?" + query.Encode()
+}
+
+// withETag responds with the user, tagged with its version
+func withETag(user *users.User, status int) api.Response {
+	res := api.JSON(status, user)
+	if res.Status == status {
+		res.Headers["ETag"] = helpers.ETag(user.Version)
+	}
+	return res
+}
+
+// Get a single user
+func (h *handler) Get(ctx context.Context, req api.Request) api.Response {
+	user, err := h.usecase.Get(ctx, req.PathParams["id"])
+	if err != nil {
+		return api.Fail(req, err)
+	}
+
+	return withETag(user, http.StatusOK)
+}
+
+// GetAll users, a page at a time, or the user with the email given
+func (h *handler) GetAll(ctx context.Context, req api.Request) api.Response {
+	if email := req.Query["email"]; email != "" {
+		return h.getByEmail(ctx, req, email)
+	}
+
+	limit := 0
+	if l, ok := req.Query["limit"]; ok {
+		var err error
+		if limit, err = strconv.Atoi(l); err != nil {
+			return api.Fail(req, users.ErrInvalidLimit)
+		}
+	}
+
+	page, next, err := h.usecase.GetAll(ctx, limit, req.Query["cursor"])
+	if err != nil {
+		return api.Fail(req, err)
+	}
+
+	return api.JSON(http.StatusOK, &users.Page{
+		Users: page,
+		Next:  nextLink(limit, next),
+	})
+}
+
+func (h *handler) getByEmail(ctx context.Context, req api.Request, email string) api.Response {
+	user, err := h.usecase.GetByEmail(ctx, email)
+	if err != nil {
+		return api.Fail(req, err)
+	}
+
+	return withETag(user, http.StatusOK)
+}
+
+// Update a single user, if it's still at the version given by If-Match,
+// responding with the user as updated
+func (h *handler) Update(ctx context.Context, req api.Request) api.Response {
+	update := &users.UpdateUser{}
+	if err := json.Unmarshal(req.Body, update); err != nil {
+		return api.Fail(req, users.ErrMalformed)
+	}
+
+	version, ok := helpers.IfMatch(req.Header("If-Match"))
+	if !ok {
+		return api.Fail(req, users.ErrVersionMismatch)
+	}
+	update.Version = version
+
+	ctx = validation.WithLocale(ctx, req.Header("Accept-Language"))
+	user, err := h.usecase.Update(ctx, req.PathParams["id"], update)
+	if err != nil {
+		return api.Fail(req, err)
+	}
+
+	return withETag(user, http.StatusOK)
+}
+
+// Create a user
+func (h *handler) Create(ctx context.Context, req api.Request) api.Response {
+	user := &users.User{}
+	if err := json.Unmarshal(req.Body, user); err != nil {
+		return api.Fail(req, users.ErrMalformed)
+	}
+
+	ctx = validation.WithLocale(ctx, req.Header("Accept-Language"))
+	if err := h.usecase.Create(ctx, user); err != nil {
+		return api.Fail(req, err)
+	}
+
+	return withETag(user, http.StatusCreated)
+}
+
+// Delete a user, if it's still at the version given by If-Match
+func (h *handler) Delete(ctx context.Context, req api.Request) api.Response {
+	version, ok := helpers.IfMatch(req.Header("If-Match"))
+	if !ok {
+		return api.Fail(req, users.ErrVersionMismatch)
+	}
+
+	if err := h.usecase.Delete(ctx, req.PathParams["id"], version); err != nil {
+		return api.Fail(req, err)
+	}
+
+	return api.Response{Status: http.StatusNoContent}
+}
+
+// Restore a deleted user, if it's still at the version given by If-Match
+func (h *handler) Restore(ctx context.Context, req api.Request) api.Response {
+	version, ok := helpers.IfMatch(req.Header("If-Match"))
+	if !ok {
+		return api.Fail(req, users.ErrVersionMismatch)
+	}
+
+	user, err := h.usecase.Restore(ctx, req.PathParams["id"], version)
+	if err != nil {
+		return api.Fail(req, err)
+	}
+
+	return withETag(user, http.StatusOK)
+}
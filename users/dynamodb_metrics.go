@@ -0,0 +1,140 @@
+package users
+
+import (
+	"context"
+	"github.com/EwanValentine/serverless-api-example/pkg/metrics"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// dynamoDBObserver is told of the capacity each request consumes,
+// and of each time one is throttled, by operation
+type dynamoDBObserver struct {
+	consumed  func(ctx context.Context, operation string, units float64)
+	throttled func(ctx context.Context, operation string)
+}
+
+// InstrumentDynamoDB counts the capacity the client's requests consume,
+// and how often they're throttled, by operation, in metrics registered
+// with reg. Requests are made to return the capacity they consume.
+func InstrumentDynamoDB(c *client.Client, reg prometheus.Registerer) {
+	consumed := metrics.Register(reg, prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "dynamodb_consumed_capacity_units_total",
+		Help: "Capacity units consumed by DynamoDB requests, by operation.",
+	}, []string{"operation"})).(*prometheus.CounterVec)
+
+	throttles := metrics.Register(reg, prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "dynamodb_throttles_total",
+		Help: "DynamoDB requests which were throttled, including those the SDK retried, by operation.",
+	}, []string{"operation"})).(*prometheus.CounterVec)
+
+	instrumentDynamoDB(c, dynamoDBObserver{
+		consumed: func(ctx context.Context, operation string, units float64) {
+			consumed.WithLabelValues(operation).Add(units)
+		},
+		throttled: func(ctx context.Context, operation string) {
+			throttles.WithLabelValues(operation).Inc()
+		},
+	})
+}
+
+// InstrumentDynamoDBEMF logs the capacity the client's requests consume,
+// and each time they're throttled, by operation, in CloudWatch's
+// embedded metric format, as InstrumentDynamoDB does for Prometheus
+func InstrumentDynamoDBEMF(c *client.Client, e *metrics.EMF) {
+	dimensions := [][]string{{"DynamoDBOperation"}}
+	instrumentDynamoDB(c, dynamoDBObserver{
+		consumed: func(ctx context.Context, operation string, units float64) {
+			e.Write(ctx, dimensions, map[string]string{"DynamoDBOperation": operation},
+				metrics.Metric{Name: "ConsumedCapacityUnits", Unit: "Count", Value: units},
+			)
+		},
+		throttled: func(ctx context.Context, operation string) {
+			e.Write(ctx, dimensions, map[string]string{"DynamoDBOperation": operation},
+				metrics.Metric{Name: "Throttles", Unit: "Count", Value: 1},
+			)
+		},
+	})
+}
+
+func instrumentDynamoDB(c *client.Client, observe dynamoDBObserver) {
+	c.Handlers.Build.PushFront(returnConsumedCapacity)
+	c.Handlers.AfterRetry.PushFront(func(r *request.Request) {
+		if throttled(r.Error) {
+			observe.throttled(r.Context(), r.Operation.Name)
+		}
+	})
+	c.Handlers.Complete.PushBack(func(r *request.Request) {
+		if units := consumedCapacity(r.Data); units > 0 {
+			observe.consumed(r.Context(), r.Operation.Name, units)
+		}
+	})
+}
+
+// returnConsumedCapacity asks for the total capacity the request
+// consumes, unless the caller has asked for something else
+func returnConsumedCapacity(r *request.Request) {
+	total := aws.String(dynamodb.ReturnConsumedCapacityTotal)
+	switch input := r.Params.(type) {
+	case *dynamodb.GetItemInput:
+		if input.ReturnConsumedCapacity == nil {
+			input.ReturnConsumedCapacity = total
+		}
+	case *dynamodb.ScanInput:
+		if input.ReturnConsumedCapacity == nil {
+			input.ReturnConsumedCapacity = total
+		}
+	case *dynamodb.QueryInput:
+		if input.ReturnConsumedCapacity == nil {
+			input.ReturnConsumedCapacity = total
+		}
+	case *dynamodb.PutItemInput:
+		if input.ReturnConsumedCapacity == nil {
+			input.ReturnConsumedCapacity = total
+		}
+	case *dynamodb.UpdateItemInput:
+		if input.ReturnConsumedCapacity == nil {
+			input.ReturnConsumedCapacity = total
+		}
+	case *dynamodb.DeleteItemInput:
+		if input.ReturnConsumedCapacity == nil {
+			input.ReturnConsumedCapacity = total
+		}
+	case *dynamodb.TransactWriteItemsInput:
+		if input.ReturnConsumedCapacity == nil {
+			input.ReturnConsumedCapacity = total
+		}
+	}
+}
+
+// consumedCapacity reported by a request's output
+func consumedCapacity(output interface{}) float64 {
+	var capacities []*dynamodb.ConsumedCapacity
+	switch output := output.(type) {
+	case *dynamodb.GetItemOutput:
+		capacities = append(capacities, output.ConsumedCapacity)
+	case *dynamodb.ScanOutput:
+		capacities = append(capacities, output.ConsumedCapacity)
+	case *dynamodb.QueryOutput:
+		capacities = append(capacities, output.ConsumedCapacity)
+	case *dynamodb.PutItemOutput:
+		capacities = append(capacities, output.ConsumedCapacity)
+	case *dynamodb.UpdateItemOutput:
+		capacities = append(capacities, output.ConsumedCapacity)
+	case *dynamodb.DeleteItemOutput:
+		capacities = append(capacities, output.ConsumedCapacity)
+	case *dynamodb.TransactWriteItemsOutput:
+		capacities = output.ConsumedCapacity
+	}
+
+	var units float64
+	for _, capacity := range capacities {
+		if capacity != nil {
+			units += aws.Float64Value(capacity.CapacityUnits)
+		}
+	}
+	return units
+}
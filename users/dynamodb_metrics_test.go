@@ -0,0 +1,143 @@
+package users
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/EwanValentine/serverless-api-example/pkg/metrics"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// fakeDynamoDB responds to GetItem with the capacity it consumed,
+// if it was asked to, and throttles everything else
+func fakeDynamoDB(t *testing.T) *dynamodb.DynamoDB {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+
+		if strings.HasSuffix(r.Header.Get("X-Amz-Target"), ".GetItem") {
+			assert.Contains(t, string(body), `"ReturnConsumedCapacity":"TOTAL"`)
+			w.Write([]byte(`{"ConsumedCapacity":{"TableName":"users","CapacityUnits":0.5},"Item":{"id":{"S":"abc123"}}}`))
+			return
+		}
+
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"__type":"com.amazonaws.dynamodb.v20120810#ProvisionedThroughputExceededException","message":"slow down"}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	sess := session.Must(session.NewSession(&aws.Config{
+		Region:      aws.String("eu-west-1"),
+		Endpoint:    aws.String(srv.URL),
+		Credentials: credentials.NewStaticCredentials("id", "secret", ""),
+		MaxRetries:  aws.Int(1),
+	}))
+	return dynamodb.New(sess)
+}
+
+func TestInstrumentDynamoDB(t *testing.T) {
+	ddb := fakeDynamoDB(t)
+	reg := prometheus.NewRegistry()
+	InstrumentDynamoDB(ddb.Client, reg)
+	repo := NewDynamoDBRepository(ddb, "users")
+
+	ctx := context.Background()
+	repo.Get(ctx, "abc123")
+	repo.Get(ctx, "abc123")
+
+	_, _, err := repo.GetAll(ctx, 10, "")
+	assert.Error(t, err)
+
+	metrics, err := reg.Gather()
+	assert.NoError(t, err)
+	values := map[string]float64{}
+	for _, family := range metrics {
+		for _, metric := range family.GetMetric() {
+			values[family.GetName()+" "+metric.GetLabel()[0].GetValue()] = metric.GetCounter().GetValue()
+		}
+	}
+
+	// The SDK retried the scan once
+	assert.Equal(t, map[string]float64{
+		"dynamodb_consumed_capacity_units_total GetItem": 1,
+		"dynamodb_throttles_total Scan":                  2,
+	}, values)
+}
+
+// emfLogs written to out, one per line
+func emfLogs(t *testing.T, out *bytes.Buffer) []map[string]interface{} {
+	var logs []map[string]interface{}
+	for _, line := range bytes.Split(bytes.TrimSpace(out.Bytes()), []byte("\n")) {
+		var l map[string]interface{}
+		assert.NoError(t, json.Unmarshal(line, &l))
+		logs = append(logs, l)
+	}
+	return logs
+}
+
+func TestInstrumentDynamoDBEMF(t *testing.T) {
+	var out bytes.Buffer
+	ddb := fakeDynamoDB(t)
+	InstrumentDynamoDBEMF(ddb.Client, metrics.NewEMF(&out, "test"))
+	repo := NewDynamoDBRepository(ddb, "users")
+
+	ctx := context.Background()
+	repo.Get(ctx, "abc123")
+	repo.GetAll(ctx, 10, "")
+
+	var logged []string
+	for _, l := range emfLogs(t, &out) {
+		for _, metric := range []string{"ConsumedCapacityUnits", "Throttles"} {
+			if value, ok := l[metric]; ok {
+				logged = append(logged, l["DynamoDBOperation"].(string)+" "+metric+" "+strconv.FormatFloat(value.(float64), 'f', -1, 64))
+			}
+		}
+	}
+	assert.Equal(t, []string{"GetItem ConsumedCapacityUnits 0.5", "Scan Throttles 1", "Scan Throttles 1"}, logged)
+}
+
+func TestMetricsMeasuresEachOperation(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	p := &Pipeline{
+		Usecase:      &Usecase{Repository: NewMemoryRepository()},
+		Interceptors: []Interceptor{Metrics(reg)},
+	}
+
+	p.Get(context.Background(), "abc123")
+	p.Purge(context.Background())
+	families, err := reg.Gather()
+	assert.NoError(t, err)
+	assert.Equal(t, "users_operation_duration_seconds", families[0].GetName())
+	var labels []string
+	for _, metric := range families[0].GetMetric() {
+		labels = append(labels, metric.GetLabel()[0].GetValue()+":"+metric.GetLabel()[1].GetValue())
+	}
+	assert.ElementsMatch(t, []string{"get:not_found", "purge:ok"}, labels)
+}
+
+func TestEMFMetricsLogsEachOperation(t *testing.T) {
+	var out bytes.Buffer
+	p := &Pipeline{
+		Usecase:      &Usecase{Repository: NewMemoryRepository()},
+		Interceptors: []Interceptor{EMFMetrics(metrics.NewEMF(&out, "test"))},
+	}
+
+	p.Get(context.Background(), "abc123")
+	logs := emfLogs(t, &out)
+	assert.Len(t, logs, 1)
+	assert.Equal(t, "get", logs[0]["Operation"])
+	assert.Equal(t, "not_found", logs[0]["Outcome"])
+	assert.Equal(t, 1.0, logs[0]["Calls"])
+	assert.Contains(t, logs[0], "OperationLatency")
+}
@@ -2,10 +2,14 @@ package users
 
 import (
 	"context"
+	"github.com/EwanValentine/serverless-api-example/pkg/apperrors"
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
 	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
-	"log"
+	"github.com/aws/aws-sdk-go/service/dynamodb/expression"
+	"strings"
+	"time"
 )
 
 // DynamoDBRepository -
@@ -19,21 +23,148 @@ func NewDynamoDBRepository(ddb *dynamodb.DynamoDB, tableName string) *DynamoDBRe
 	return &DynamoDBRepository{ddb, tableName}
 }
 
-// Get a user
+const (
+	// userExists guards writes, so they fail rather than
+	// creating or silently skipping unknown users
+	userExists = "attribute_exists(id)"
+
+	// itemMissing guards puts, so they never overwrite an existing item
+	itemMissing = "attribute_not_exists(id)"
+
+	// emailClaimPrefix namespaces the items which reserve an email
+	// address for a single user. Claims live in the users table, so
+	// they can be written in the same transaction as the user.
+	emailClaimPrefix = "email#"
+)
+
+type emailClaim struct {
+	ID     string `json:"id"`
+	UserID string `json:"userId"`
+}
+
+func claimID(email string) string {
+	return emailClaimPrefix + normalizeEmail(email)
+}
+
+func key(id string) map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{
+		"id": {
+			S: aws.String(id),
+		},
+	}
+}
+
+// mapErr turns a failed existence condition into ErrNotFound
+func mapErr(err error) error {
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+		return ErrNotFound
+	}
+	return err
+}
+
+// throttleErr marks errors from DynamoDB turning us away, once the SDK has
+// given up retrying, so clients know to back off and try again later
+func throttleErr(err error) error {
+	if throttled(err) {
+		return apperrors.Wrap(err, apperrors.Throttled, "too many requests, try again later")
+	}
+	return err
+}
+
+// throttled is whether err is DynamoDB turning us away
+func throttled(err error) bool {
+	aerr, ok := err.(awserr.Error)
+	if !ok {
+		return false
+	}
+
+	switch aerr.Code() {
+	case dynamodb.ErrCodeProvisionedThroughputExceededException,
+		dynamodb.ErrCodeRequestLimitExceeded,
+		"ThrottlingException":
+		return true
+	}
+	return false
+}
+
+// transactionErr maps the failed condition of each item in a cancelled
+// transaction onto the error given for that item, in the same order.
+func transactionErr(err error, conditionErrs ...error) error {
+	aerr, ok := err.(awserr.Error)
+	if !ok || aerr.Code() != dynamodb.ErrCodeTransactionCanceledException {
+		return throttleErr(err)
+	}
+
+	reasons := cancellationReasons(aerr.Message())
+	for i, reason := range reasons {
+		if reason == "ConditionalCheckFailed" && i < len(conditionErrs) && conditionErrs[i] != nil {
+			return conditionErrs[i]
+		}
+	}
+	for _, reason := range reasons {
+		if reason == "ThrottlingError" {
+			return apperrors.Wrap(err, apperrors.Throttled, "too many requests, try again later")
+		}
+	}
+	return err
+}
+
+// cancellationReasons are only reported as part of the error
+// message, e.g. "Transaction cancelled, ... [None, ConditionalCheckFailed]"
+func cancellationReasons(message string) []string {
+	start, end := strings.LastIndex(message, "["), strings.LastIndex(message, "]")
+	if start < 0 || end < start {
+		return nil
+	}
+
+	reasons := strings.Split(message[start+1:end], ",")
+	for i, reason := range reasons {
+		reasons[i] = strings.TrimSpace(reason)
+	}
+	return reasons
+}
+
+// Get a user, deleted users are hidden
 func (r *DynamoDBRepository) Get(ctx context.Context, id string) (*User, error) {
+	return r.live(ctx, id, false)
+}
+
+// live gets a user, unless they've been deleted
+func (r *DynamoDBRepository) live(ctx context.Context, id string, consistent bool) (*User, error) {
+	user, err := r.get(ctx, id, consistent)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.DeletedAt != nil {
+		return nil, ErrNotFound
+	}
+	return user, nil
+}
+
+// get a user, whether they've been deleted or not. Reads which
+// writes are conditional on must be consistent, or they could
+// see a version of the user which has already been replaced.
+func (r *DynamoDBRepository) get(ctx context.Context, id string, consistent bool) (*User, error) {
+	// Email claims share the table, but they aren't users
+	if strings.HasPrefix(id, emailClaimPrefix) {
+		return nil, ErrNotFound
+	}
+
 	user := &User{}
 	input := &dynamodb.GetItemInput{
-		TableName: aws.String(r.tableName),
-		Key: map[string]*dynamodb.AttributeValue{
-			"id": {
-				S: aws.String(id),
-			},
-		},
+		TableName:      aws.String(r.tableName),
+		Key:            key(id),
+		ConsistentRead: aws.Bool(consistent),
 	}
 
 	result, err := r.session.GetItemWithContext(ctx, input)
 	if err != nil {
-		return nil, err
+		return nil, throttleErr(err)
+	}
+
+	if len(result.Item) == 0 {
+		return nil, ErrNotFound
 	}
 
 	if err := dynamodbattribute.UnmarshalMap(result.Item, &user); err != nil {
@@ -43,88 +174,501 @@ func (r *DynamoDBRepository) Get(ctx context.Context, id string) (*User, error)
 	return user, nil
 }
 
-// GetAll users
-func (r *DynamoDBRepository) GetAll(ctx context.Context) ([]*User, error) {
-	users := make([]*User, 0)
-	result, err := r.session.ScanWithContext(ctx, &dynamodb.ScanInput{
-		TableName: aws.String(r.tableName),
-	})
+// GetByEmail finds a user through the claim on their email
+func (r *DynamoDBRepository) GetByEmail(ctx context.Context, email string) (*User, error) {
+	claim := &emailClaim{}
+	input := &dynamodb.GetItemInput{
+		TableName:      aws.String(r.tableName),
+		Key:            key(claimID(email)),
+		ConsistentRead: aws.Bool(true),
+	}
+
+	result, err := r.session.GetItemWithContext(ctx, input)
 	if err != nil {
-		return nil, err
+		return nil, throttleErr(err)
+	}
+
+	if len(result.Item) == 0 {
+		return nil, ErrNotFound
 	}
 
-	if err := dynamodbattribute.UnmarshalListOfMaps(result.Items, &users); err != nil {
+	if err := dynamodbattribute.UnmarshalMap(result.Item, &claim); err != nil {
 		return nil, err
 	}
 
-	return users, nil
+	return r.Get(ctx, claim.UserID)
 }
 
-type updateUser struct {
-	Name  string `json:":n"`
-	Age   uint32 `json:":a"`
-	Email string `json:":e"`
+// GetAll users, a page at a time. Scans stop at 1MB, and the email
+// claims and deleted users are filtered out, so we keep scanning
+// until the page is full or the table is exhausted.
+func (r *DynamoDBRepository) GetAll(ctx context.Context, limit int, cursor string) ([]*User, string, error) {
+	filter := expression.Not(expression.Name("id").BeginsWith(emailClaimPrefix)).
+		And(expression.AttributeNotExists(expression.Name("deletedAt")))
+	expr, err := expression.NewBuilder().WithFilter(filter).Build()
+	if err != nil {
+		return nil, "", err
+	}
+
+	users := make([]*User, 0, limit)
+	input := &dynamodb.ScanInput{
+		TableName:                 aws.String(r.tableName),
+		Limit:                     aws.Int64(int64(limit)),
+		FilterExpression:          expr.Filter(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+	}
+
+	if cursor != "" {
+		id, err := decodeCursor(cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		input.ExclusiveStartKey = key(id)
+	}
+
+	for {
+		result, err := r.session.ScanWithContext(ctx, input)
+		if err != nil {
+			return nil, "", throttleErr(err)
+		}
+
+		page := make([]*User, 0)
+		if err := dynamodbattribute.UnmarshalListOfMaps(result.Items, &page); err != nil {
+			return nil, "", err
+		}
+		users = append(users, page...)
+
+		if len(result.LastEvaluatedKey) == 0 {
+			return users, "", nil
+		}
+
+		if len(users) >= limit {
+			return users, encodeCursor(aws.StringValue(result.LastEvaluatedKey["id"].S)), nil
+		}
+
+		input.ExclusiveStartKey = result.LastEvaluatedKey
+		input.Limit = aws.Int64(int64(limit - len(users)))
+	}
 }
 
 type userKey struct {
 	ID string `json:":id"`
 }
 
-// Update a user
-func (r *DynamoDBRepository) Update(ctx context.Context, id string, user *UpdateUser) error {
-	log.Println("id", id)
-	update, err := dynamodbattribute.MarshalMap(&updateUser{
-		Name:  user.Name,
-		Age:   user.Age,
-		Email: user.Email,
-	})
+// Update a user, only setting the fields which are present, and
+// bumping the version. Changing the email moves the user's claim
+// over to the new address.
+func (r *DynamoDBRepository) Update(ctx context.Context, id string, user *UpdateUser) (*User, error) {
+	if user.Email != nil {
+		current, err := r.live(ctx, id, true)
+		if err != nil {
+			return nil, err
+		}
+
+		if claimID(*user.Email) != claimID(current.Email) {
+			return r.updateEmail(ctx, current, user)
+		}
+	}
+
+	condition := expression.AttributeExists(expression.Name("id")).
+		And(expression.AttributeNotExists(expression.Name("deletedAt")))
+	if user.Version != 0 {
+		condition = condition.And(expression.Name("version").Equal(expression.Value(user.Version)))
+	}
+
+	expr, err := updateBuilder(user, condition).Build()
 	if err != nil {
-		return nil
+		return nil, err
 	}
 
 	input := &dynamodb.UpdateItemInput{
-		Key: map[string]*dynamodb.AttributeValue{
-			"id": {
-				S: aws.String(id),
-			},
-		},
-		ExpressionAttributeValues: update,
+		Key:                       key(id),
+		ExpressionAttributeValues: expr.Values(),
+		ExpressionAttributeNames:  expr.Names(),
 		TableName:                 aws.String(r.tableName),
-		UpdateExpression:          aws.String("set #uname = :n, age = :a, email = :e"),
-		ReturnValues:              aws.String("UPDATED_NEW"),
-		ExpressionAttributeNames: map[string]*string{
-			"#uname": aws.String("name"),
+		UpdateExpression:          expr.Update(),
+		ConditionExpression:       expr.Condition(),
+		ReturnValues:              aws.String("ALL_NEW"),
+	}
+	result, err := r.session.UpdateItemWithContext(ctx, input)
+	if err != nil {
+		if mapErr(err) == ErrNotFound {
+			return nil, r.conditionErr(ctx, id)
+		}
+		return nil, throttleErr(err)
+	}
+
+	updated := &User{}
+	if err := dynamodbattribute.UnmarshalMap(result.Attributes, &updated); err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+// conditionErr works out why an update's condition failed, as
+// DynamoDB doesn't say whether the user or the version was missing.
+func (r *DynamoDBRepository) conditionErr(ctx context.Context, id string) error {
+	if _, err := r.live(ctx, id, true); err != nil {
+		return err
+	}
+	return ErrVersionMismatch
+}
+
+// updateEmail updates the user, releases their old email
+// and claims the new one, all or nothing.
+func (r *DynamoDBRepository) updateEmail(ctx context.Context, current *User, user *UpdateUser) (*User, error) {
+	if user.Version != 0 && user.Version != current.Version {
+		return nil, ErrVersionMismatch
+	}
+
+	// Make sure nothing has changed since we read the email we're releasing
+	expr, err := updateBuilder(user, versionIs(current.Version)).Build()
+	if err != nil {
+		return nil, err
+	}
+
+	claim, err := dynamodbattribute.MarshalMap(&emailClaim{
+		ID:     claimID(*user.Email),
+		UserID: current.ID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = r.session.TransactWriteItemsWithContext(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: []*dynamodb.TransactWriteItem{
+			r.transactUpdate(current.ID, expr),
+			{
+				Delete: &dynamodb.Delete{
+					TableName: aws.String(r.tableName),
+					Key:       key(claimID(current.Email)),
+				},
+			},
+			{
+				Put: &dynamodb.Put{
+					TableName:           aws.String(r.tableName),
+					Item:                claim,
+					ConditionExpression: aws.String(itemMissing),
+				},
+			},
 		},
+	})
+	if err = transactionErr(err, ErrNotFound, nil, ErrEmailTaken); err != nil {
+		if err == ErrNotFound {
+			return nil, r.conditionErr(ctx, current.ID)
+		}
+		return nil, err
 	}
-	_, err = r.session.UpdateItemWithContext(ctx, input)
-	return err
+
+	// Transactions can't return the new item, but the
+	// condition means we know exactly what it looks like.
+	updated := *current
+	updated.apply(user)
+	return &updated, nil
+}
+
+// versionIs checks the user exists, and is still at the version
+// given. Users written before versioning have no version yet.
+func versionIs(version int64) expression.ConditionBuilder {
+	condition := expression.Name("version").Equal(expression.Value(version))
+	if version == 0 {
+		condition = expression.AttributeNotExists(expression.Name("version"))
+	}
+	return expression.AttributeExists(expression.Name("id")).And(condition)
+}
+
+// nextVersion bumps the version, from zero for users written before versioning
+func nextVersion() expression.SetValueBuilder {
+	return expression.Plus(
+		expression.IfNotExists(expression.Name("version"), expression.Value(0)),
+		expression.Value(1),
+	)
+}
+
+// claimOwned checks the email claim belongs to the user. Users created
+// before emails were claimed have no claim, which is fine too.
+func claimOwned(id string) expression.ConditionBuilder {
+	return expression.AttributeNotExists(expression.Name("id")).
+		Or(expression.Name("userId").Equal(expression.Value(id)))
+}
+
+// updateBuilder sets each field present on the update, and bumps
+// the version, under the given condition.
+func updateBuilder(user *UpdateUser, condition expression.ConditionBuilder) expression.Builder {
+	update := expression.Set(expression.Name("version"), nextVersion())
+
+	if user.Name != nil {
+		update = update.Set(expression.Name("name"), expression.Value(*user.Name))
+	}
+	if user.Age != nil {
+		update = update.Set(expression.Name("age"), expression.Value(*user.Age))
+	}
+	if user.Email != nil {
+		update = update.Set(expression.Name("email"), expression.Value(*user.Email))
+	}
+	if !user.UpdatedAt.IsZero() {
+		update = update.Set(expression.Name("updatedAt"), expression.Value(user.UpdatedAt))
+	}
+
+	return expression.NewBuilder().
+		WithCondition(condition).
+		WithUpdate(update)
 }
 
-// Create a user
+// Create a user, claiming their email in the same transaction
 func (r *DynamoDBRepository) Create(ctx context.Context, user *User) error {
 	item, err := dynamodbattribute.MarshalMap(user)
 	if err != nil {
 		return err
 	}
 
-	input := &dynamodb.PutItemInput{
-		Item:      item,
-		TableName: aws.String(r.tableName),
+	claim, err := dynamodbattribute.MarshalMap(&emailClaim{
+		ID:     claimID(user.Email),
+		UserID: user.ID,
+	})
+	if err != nil {
+		return err
 	}
-	_, err = r.session.PutItemWithContext(ctx, input)
-	return err
-}
 
-// Delete a user
-func (r *DynamoDBRepository) Delete(ctx context.Context, id string) error {
-	input := &dynamodb.DeleteItemInput{
-		TableName: aws.String(r.tableName),
-		Key: map[string]*dynamodb.AttributeValue{
-			"id": {
-				S: aws.String(id),
+	_, err = r.session.TransactWriteItemsWithContext(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: []*dynamodb.TransactWriteItem{
+			{
+				Put: &dynamodb.Put{
+					TableName:           aws.String(r.tableName),
+					Item:                item,
+					ConditionExpression: aws.String(itemMissing),
+				},
+			},
+			{
+				Put: &dynamodb.Put{
+					TableName:           aws.String(r.tableName),
+					Item:                claim,
+					ConditionExpression: aws.String(itemMissing),
+				},
 			},
 		},
+	})
+	return transactionErr(err, ErrUserExists, ErrEmailTaken)
+}
+
+// retryRaces repeats a write which is conditional on the version of the
+// user it read first, when the user changed in between. Callers who
+// didn't ask for a version shouldn't fail because they lost a race.
+func retryRaces(version int64, write func() error) error {
+	for {
+		err := write()
+		if err != ErrVersionMismatch || version != 0 {
+			return err
+		}
+	}
+}
+
+// Delete a user, softly. They're hidden, and their email kept, until
+// they're restored or purged. DynamoDB's TTL removes them at purgeAt,
+// as a backstop to Purge.
+func (r *DynamoDBRepository) Delete(ctx context.Context, id string, version int64, deletedAt, purgeAt time.Time) error {
+	return retryRaces(version, func() error {
+		return r.delete(ctx, id, version, deletedAt, purgeAt)
+	})
+}
+
+func (r *DynamoDBRepository) delete(ctx context.Context, id string, version int64, deletedAt, purgeAt time.Time) error {
+	user, err := r.live(ctx, id, true)
+	if err != nil {
+		return err
+	}
+
+	if version != 0 && version != user.Version {
+		return ErrVersionMismatch
+	}
+
+	userExpr, err := expression.NewBuilder().
+		WithCondition(versionIs(user.Version)).
+		WithUpdate(expression.
+			Set(expression.Name("deletedAt"), expression.Value(deletedAt.Unix())).
+			Set(expression.Name("purgeAt"), expression.Value(purgeAt.Unix())).
+			Set(expression.Name("updatedAt"), expression.Value(deletedAt)).
+			Set(expression.Name("version"), nextVersion())).
+		Build()
+	if err != nil {
+		return err
+	}
+
+	claimExpr, err := expression.NewBuilder().
+		WithCondition(claimOwned(id)).
+		WithUpdate(expression.
+			Set(expression.Name("userId"), expression.Value(id)).
+			Set(expression.Name("purgeAt"), expression.Value(purgeAt.Unix()))).
+		Build()
+	if err != nil {
+		return err
+	}
+
+	_, err = r.session.TransactWriteItemsWithContext(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: []*dynamodb.TransactWriteItem{
+			r.transactUpdate(id, userExpr),
+			r.transactUpdate(claimID(user.Email), claimExpr),
+		},
+	})
+	if err = transactionErr(err, ErrNotFound); err == ErrNotFound {
+		return r.conditionErr(ctx, id)
 	}
-	_, err := r.session.DeleteItemWithContext(ctx, input)
 	return err
 }
+
+// Restore a deleted user
+func (r *DynamoDBRepository) Restore(ctx context.Context, id string, version int64, restoredAt time.Time) (*User, error) {
+	var user *User
+	err := retryRaces(version, func() (err error) {
+		user, err = r.restore(ctx, id, version, restoredAt)
+		return err
+	})
+	return user, err
+}
+
+func (r *DynamoDBRepository) restore(ctx context.Context, id string, version int64, restoredAt time.Time) (*User, error) {
+	user, err := r.get(ctx, id, true)
+	if err != nil {
+		return nil, err
+	}
+	if user.DeletedAt == nil {
+		return nil, ErrNotFound
+	}
+	if version != 0 && version != user.Version {
+		return nil, ErrVersionMismatch
+	}
+
+	userExpr, err := expression.NewBuilder().
+		WithCondition(versionIs(user.Version).And(expression.AttributeExists(expression.Name("deletedAt")))).
+		WithUpdate(expression.
+			Remove(expression.Name("deletedAt")).
+			Remove(expression.Name("purgeAt")).
+			Set(expression.Name("updatedAt"), expression.Value(restoredAt)).
+			Set(expression.Name("version"), nextVersion())).
+		Build()
+	if err != nil {
+		return nil, err
+	}
+
+	claimExpr, err := expression.NewBuilder().
+		WithCondition(claimOwned(id)).
+		WithUpdate(expression.
+			Set(expression.Name("userId"), expression.Value(id)).
+			Remove(expression.Name("purgeAt"))).
+		Build()
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = r.session.TransactWriteItemsWithContext(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: []*dynamodb.TransactWriteItem{
+			r.transactUpdate(id, userExpr),
+			r.transactUpdate(claimID(user.Email), claimExpr),
+		},
+	})
+	if err = transactionErr(err, ErrVersionMismatch, ErrEmailTaken); err != nil {
+		return nil, err
+	}
+
+	user.DeletedAt = nil
+	user.UpdatedAt = restoredAt
+	user.Version++
+	return user, nil
+}
+
+// Purge users deleted before the given time for good, releasing their emails
+func (r *DynamoDBRepository) Purge(ctx context.Context, before time.Time) (int, error) {
+	expr, err := expression.NewBuilder().
+		WithFilter(expression.Name("deletedAt").LessThan(expression.Value(before.Unix()))).
+		Build()
+	if err != nil {
+		return 0, err
+	}
+
+	input := &dynamodb.ScanInput{
+		TableName:                 aws.String(r.tableName),
+		FilterExpression:          expr.Filter(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+	}
+
+	purged := 0
+	for {
+		result, err := r.session.ScanWithContext(ctx, input)
+		if err != nil {
+			return purged, throttleErr(err)
+		}
+
+		users := make([]*User, 0)
+		if err := dynamodbattribute.UnmarshalListOfMaps(result.Items, &users); err != nil {
+			return purged, err
+		}
+
+		for _, user := range users {
+			err := r.purge(ctx, user)
+			if err == ErrNotFound {
+				// Restored or purged since we scanned
+				continue
+			}
+			if err != nil {
+				return purged, err
+			}
+			purged++
+		}
+
+		if len(result.LastEvaluatedKey) == 0 {
+			return purged, nil
+		}
+		input.ExclusiveStartKey = result.LastEvaluatedKey
+	}
+}
+
+func (r *DynamoDBRepository) purge(ctx context.Context, user *User) error {
+	userExpr, err := expression.NewBuilder().
+		WithCondition(expression.AttributeExists(expression.Name("deletedAt"))).
+		Build()
+	if err != nil {
+		return err
+	}
+
+	claimExpr, err := expression.NewBuilder().
+		WithCondition(claimOwned(user.ID)).
+		Build()
+	if err != nil {
+		return err
+	}
+
+	_, err = r.session.TransactWriteItemsWithContext(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: []*dynamodb.TransactWriteItem{
+			r.transactDelete(user.ID, userExpr),
+			r.transactDelete(claimID(user.Email), claimExpr),
+		},
+	})
+	return transactionErr(err, ErrNotFound)
+}
+
+func (r *DynamoDBRepository) transactUpdate(id string, expr expression.Expression) *dynamodb.TransactWriteItem {
+	return &dynamodb.TransactWriteItem{
+		Update: &dynamodb.Update{
+			TableName:                 aws.String(r.tableName),
+			Key:                       key(id),
+			UpdateExpression:          expr.Update(),
+			ConditionExpression:       expr.Condition(),
+			ExpressionAttributeNames:  expr.Names(),
+			ExpressionAttributeValues: expr.Values(),
+		},
+	}
+}
+
+func (r *DynamoDBRepository) transactDelete(id string, expr expression.Expression) *dynamodb.TransactWriteItem {
+	return &dynamodb.TransactWriteItem{
+		Delete: &dynamodb.Delete{
+			TableName:                 aws.String(r.tableName),
+			Key:                       key(id),
+			ConditionExpression:       expr.Condition(),
+			ExpressionAttributeNames:  expr.Names(),
+			ExpressionAttributeValues: expr.Values(),
+		},
+	}
+}
@@ -0,0 +1,488 @@
+package users
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/EwanValentine/serverless-api-example/pkg/apperrors"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// reply is how the scripted fake answers a request
+type reply struct {
+	status int
+	body   string
+}
+
+// ok replies with the body given
+func ok(body string) reply {
+	return reply{http.StatusOK, body}
+}
+
+// fails replies with a DynamoDB error
+func fails(code, message string) reply {
+	body, _ := json.Marshal(map[string]string{
+		"__type":  "com.amazonaws.dynamodb.v20120810#" + code,
+		"message": message,
+	})
+	return reply{http.StatusBadRequest, string(body)}
+}
+
+// cancelled replies that a transaction was cancelled, for the reasons given
+func cancelled(reasons ...string) reply {
+	return fails(dynamodb.ErrCodeTransactionCanceledException,
+		"Transaction cancelled, please refer cancellation reasons for specific reasons ["+strings.Join(reasons, ", ")+"]")
+}
+
+// received is a request made to the scripted fake
+type received struct {
+	Operation string
+	Body      []byte
+}
+
+// decode the request into the SDK's input for the operation, which
+// has the same shape as the JSON sent
+func (r received) decode(t *testing.T, input interface{}) {
+	assert.NoError(t, json.Unmarshal(r.Body, input))
+}
+
+// scriptedDynamoDB answers each request with the next of the replies,
+// and records them, so tests can check exactly what was asked for
+func scriptedDynamoDB(t *testing.T, replies ...reply) (*dynamodb.DynamoDB, func() []received) {
+	var mu sync.Mutex
+	var requests []received
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		target := r.Header.Get("X-Amz-Target")
+		req := received{Operation: target[strings.Index(target, ".")+1:], Body: body}
+
+		mu.Lock()
+		requests = append(requests, req)
+		n := len(requests)
+		mu.Unlock()
+
+		if n > len(replies) {
+			t.Errorf("unexpected %s request: %s", req.Operation, body)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+		w.WriteHeader(replies[n-1].status)
+		w.Write([]byte(replies[n-1].body))
+	}))
+	t.Cleanup(srv.Close)
+
+	sess := session.Must(session.NewSession(&aws.Config{
+		Region:      aws.String("eu-west-1"),
+		Endpoint:    aws.String(srv.URL),
+		Credentials: credentials.NewStaticCredentials("id", "secret", ""),
+		MaxRetries:  aws.Int(0),
+	}))
+	return dynamodb.New(sess), func() []received {
+		mu.Lock()
+		defer mu.Unlock()
+		return requests
+	}
+}
+
+// storedUser is how DynamoDB returns a live user, at the version given
+func storedUser(version int) string {
+	return `{"Item":{"id":{"S":"abc123"},"name":{"S":"Ewan"},"email":{"S":"test@test.com"},"version":{"N":"` + strconv.Itoa(version) + `"}}}`
+}
+
+// deletedUser is how DynamoDB returns a deleted user, at the version given
+func deletedUser(version int) string {
+	return `{"Item":{"id":{"S":"abc123"},"name":{"S":"Ewan"},"email":{"S":"test@test.com"},"version":{"N":"` + strconv.Itoa(version) + `"},"deletedAt":{"N":"1567339200"}}}`
+}
+
+var placeholder = regexp.MustCompile(`[#:][0-9]+`)
+
+// expand the placeholders in an expression, so it reads as DynamoDB will evaluate it
+func expand(expr *string, names map[string]*string, values map[string]*dynamodb.AttributeValue) string {
+	return strings.TrimSpace(placeholder.ReplaceAllStringFunc(aws.StringValue(expr), func(p string) string {
+		if p[0] == '#' {
+			return aws.StringValue(names[p])
+		}
+
+		value := values[p]
+		switch {
+		case value.N != nil:
+			return aws.StringValue(value.N)
+		case value.S != nil:
+			return strconv.Quote(aws.StringValue(value.S))
+		}
+		return value.String()
+	}))
+}
+
+func TestTransactionErrMapsFailedConditions(t *testing.T) {
+	cancelled := awserr.New(
+		dynamodb.ErrCodeTransactionCanceledException,
+		"Transaction cancelled, please refer cancellation reasons for specific reasons [None, ConditionalCheckFailed]",
+		nil,
+	)
+	assert.Equal(t, ErrEmailTaken, transactionErr(cancelled, ErrNotFound, ErrEmailTaken))
+
+	exists := awserr.New(
+		dynamodb.ErrCodeTransactionCanceledException,
+		"Transaction cancelled, please refer cancellation reasons for specific reasons [ConditionalCheckFailed, None]",
+		nil,
+	)
+	assert.Equal(t, ErrUserExists, transactionErr(exists, ErrUserExists, ErrEmailTaken))
+
+	// Conditions without a domain error are left as they are
+	assert.Equal(t, cancelled, transactionErr(cancelled, ErrNotFound))
+
+	other := errors.New("boom")
+	assert.Equal(t, other, transactionErr(other, ErrNotFound))
+	assert.Nil(t, transactionErr(nil, ErrNotFound))
+}
+
+func TestThrottlingIsReported(t *testing.T) {
+	throttled := awserr.New(dynamodb.ErrCodeProvisionedThroughputExceededException, "slow down", nil)
+	assert.Equal(t, apperrors.Throttled, apperrors.CodeOf(throttleErr(throttled)))
+
+	cancelled := awserr.New(
+		dynamodb.ErrCodeTransactionCanceledException,
+		"Transaction cancelled, please refer cancellation reasons for specific reasons [ThrottlingError, None]",
+		nil,
+	)
+	assert.Equal(t, apperrors.Throttled, apperrors.CodeOf(transactionErr(cancelled, ErrNotFound)))
+
+	other := errors.New("boom")
+	assert.Equal(t, other, throttleErr(other))
+}
+
+func TestDynamoDBRepositoryWontRestoreLiveUsers(t *testing.T) {
+	// The fake finds a live user, and throttles any write
+	ddb := fakeDynamoDB(t)
+	InstrumentDynamoDB(ddb.Client, prometheus.NewRegistry())
+	repo := NewDynamoDBRepository(ddb, "users")
+
+	_, err := repo.Restore(context.Background(), "abc123", 0, time.Now())
+	assert.Equal(t, ErrNotFound, err)
+}
+
+func TestDynamoDBRepositoryDeletesWhoeverWinsARace(t *testing.T) {
+	// Somebody updates the user between our read and write
+	ddb, requests := scriptedDynamoDB(t,
+		ok(storedUser(1)),
+		cancelled("ConditionalCheckFailed", "None"),
+		ok(storedUser(2)),
+		ok(storedUser(2)),
+		ok(`{}`),
+	)
+	repo := NewDynamoDBRepository(ddb, "users")
+
+	err := repo.Delete(context.Background(), "abc123", 0, now, now.Add(time.Hour))
+	assert.NoError(t, err)
+
+	var operations []string
+	for _, req := range requests() {
+		operations = append(operations, req.Operation)
+		if req.Operation == "GetItem" {
+			get := &dynamodb.GetItemInput{}
+			req.decode(t, get)
+			assert.True(t, aws.BoolValue(get.ConsistentRead))
+		}
+	}
+	assert.Equal(t, []string{"GetItem", "TransactWriteItems", "GetItem", "GetItem", "TransactWriteItems"}, operations)
+
+	// The retry is conditional on the version it read
+	write := &dynamodb.TransactWriteItemsInput{}
+	requests()[4].decode(t, write)
+	update := write.TransactItems[0].Update
+	assert.Equal(t, "(attribute_exists (#0)) AND (#1 = :0)", aws.StringValue(update.ConditionExpression))
+	assert.Equal(t, "2", aws.StringValue(update.ExpressionAttributeValues[":0"].N))
+}
+
+func TestDynamoDBRepositoryWontDeleteVersionsSinceReplaced(t *testing.T) {
+	ddb, _ := scriptedDynamoDB(t,
+		ok(storedUser(1)),
+		cancelled("ConditionalCheckFailed", "None"),
+		ok(storedUser(2)),
+	)
+	repo := NewDynamoDBRepository(ddb, "users")
+
+	err := repo.Delete(context.Background(), "abc123", 1, now, now.Add(time.Hour))
+	assert.Equal(t, ErrVersionMismatch, err)
+}
+
+func TestDynamoDBRepositoryUpdatesOnlyTheFieldsGiven(t *testing.T) {
+	ddb, requests := scriptedDynamoDB(t, ok(`{"Attributes":{"id":{"S":"abc123"},"age":{"N":"31"},"version":{"N":"3"}}}`))
+	repo := NewDynamoDBRepository(ddb, "users")
+
+	age := uint32(31)
+	updated, err := repo.Update(context.Background(), "abc123", &UpdateUser{Age: &age, Version: 2, UpdatedAt: now})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), updated.Version)
+
+	input := &dynamodb.UpdateItemInput{}
+	requests()[0].decode(t, input)
+	assert.Equal(t, "abc123", aws.StringValue(input.Key["id"].S))
+	assert.Equal(t, "ALL_NEW", aws.StringValue(input.ReturnValues))
+	assert.Equal(t,
+		"((attribute_exists (id)) AND (attribute_not_exists (deletedAt))) AND (version = 2)",
+		expand(input.ConditionExpression, input.ExpressionAttributeNames, input.ExpressionAttributeValues))
+	assert.Equal(t,
+		`SET version = if_not_exists(version, 0) + 1, age = 31, updatedAt = "2019-09-01T12:00:00Z"`,
+		expand(input.UpdateExpression, input.ExpressionAttributeNames, input.ExpressionAttributeValues))
+}
+
+func TestDynamoDBRepositoryUpdatesAnyVersionWhenNoneIsGiven(t *testing.T) {
+	ddb, requests := scriptedDynamoDB(t, ok(`{"Attributes":{"id":{"S":"abc123"},"version":{"N":"3"}}}`))
+	repo := NewDynamoDBRepository(ddb, "users")
+
+	name := "Ewan"
+	_, err := repo.Update(context.Background(), "abc123", &UpdateUser{Name: &name})
+	assert.NoError(t, err)
+
+	input := &dynamodb.UpdateItemInput{}
+	requests()[0].decode(t, input)
+	assert.Equal(t,
+		"(attribute_exists (id)) AND (attribute_not_exists (deletedAt))",
+		expand(input.ConditionExpression, input.ExpressionAttributeNames, input.ExpressionAttributeValues))
+	assert.Equal(t,
+		`SET version = if_not_exists(version, 0) + 1, name = "Ewan"`,
+		expand(input.UpdateExpression, input.ExpressionAttributeNames, input.ExpressionAttributeValues))
+}
+
+func TestDynamoDBRepositoryMovesTheEmailClaim(t *testing.T) {
+	ddb, requests := scriptedDynamoDB(t, ok(storedUser(1)), ok(`{}`))
+	repo := NewDynamoDBRepository(ddb, "users")
+
+	email := "New@Test.com"
+	updated, err := repo.Update(context.Background(), "abc123", &UpdateUser{Email: &email, Version: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, email, updated.Email)
+	assert.Equal(t, int64(2), updated.Version)
+
+	input := &dynamodb.TransactWriteItemsInput{}
+	requests()[1].decode(t, input)
+	assert.Len(t, input.TransactItems, 3)
+
+	update := input.TransactItems[0].Update
+	assert.Equal(t, "abc123", aws.StringValue(update.Key["id"].S))
+	assert.Equal(t,
+		"(attribute_exists (id)) AND (version = 1)",
+		expand(update.ConditionExpression, update.ExpressionAttributeNames, update.ExpressionAttributeValues))
+	assert.Equal(t,
+		`SET version = if_not_exists(version, 0) + 1, email = "New@Test.com"`,
+		expand(update.UpdateExpression, update.ExpressionAttributeNames, update.ExpressionAttributeValues))
+
+	release := input.TransactItems[1].Delete
+	assert.Equal(t, "email#test@test.com", aws.StringValue(release.Key["id"].S))
+
+	claim := input.TransactItems[2].Put
+	assert.Equal(t, "email#new@test.com", aws.StringValue(claim.Item["id"].S))
+	assert.Equal(t, "abc123", aws.StringValue(claim.Item["userId"].S))
+	assert.Equal(t, itemMissing, aws.StringValue(claim.ConditionExpression))
+}
+
+func TestDynamoDBRepositoryCreatesUsersWithTheirEmailClaim(t *testing.T) {
+	ddb, requests := scriptedDynamoDB(t, ok(`{}`))
+	repo := NewDynamoDBRepository(ddb, "users")
+
+	err := repo.Create(context.Background(), &User{ID: "abc123", Name: "Ewan", Email: "Test@Test.com", Version: 1})
+	assert.NoError(t, err)
+
+	input := &dynamodb.TransactWriteItemsInput{}
+	requests()[0].decode(t, input)
+	assert.Len(t, input.TransactItems, 2)
+
+	user := input.TransactItems[0].Put
+	assert.Equal(t, "abc123", aws.StringValue(user.Item["id"].S))
+	assert.Equal(t, "Test@Test.com", aws.StringValue(user.Item["email"].S))
+	assert.Equal(t, "1", aws.StringValue(user.Item["version"].N))
+	assert.Equal(t, itemMissing, aws.StringValue(user.ConditionExpression))
+
+	claim := input.TransactItems[1].Put
+	assert.Equal(t, "email#test@test.com", aws.StringValue(claim.Item["id"].S))
+	assert.Equal(t, "abc123", aws.StringValue(claim.Item["userId"].S))
+	assert.Equal(t, itemMissing, aws.StringValue(claim.ConditionExpression))
+}
+
+func TestDynamoDBRepositoryDeletesSoftly(t *testing.T) {
+	ddb, requests := scriptedDynamoDB(t, ok(storedUser(1)), ok(`{}`))
+	repo := NewDynamoDBRepository(ddb, "users")
+
+	err := repo.Delete(context.Background(), "abc123", 1, now, now.Add(time.Hour))
+	assert.NoError(t, err)
+
+	input := &dynamodb.TransactWriteItemsInput{}
+	requests()[1].decode(t, input)
+	assert.Len(t, input.TransactItems, 2)
+
+	user := input.TransactItems[0].Update
+	assert.Equal(t,
+		"(attribute_exists (id)) AND (version = 1)",
+		expand(user.ConditionExpression, user.ExpressionAttributeNames, user.ExpressionAttributeValues))
+	assert.Equal(t,
+		`SET deletedAt = 1567339200, purgeAt = 1567342800, updatedAt = "2019-09-01T12:00:00Z", version = if_not_exists(version, 0) + 1`,
+		expand(user.UpdateExpression, user.ExpressionAttributeNames, user.ExpressionAttributeValues))
+
+	claim := input.TransactItems[1].Update
+	assert.Equal(t, "email#test@test.com", aws.StringValue(claim.Key["id"].S))
+	assert.Equal(t,
+		`(attribute_not_exists (id)) OR (userId = "abc123")`,
+		expand(claim.ConditionExpression, claim.ExpressionAttributeNames, claim.ExpressionAttributeValues))
+	assert.Equal(t,
+		`SET userId = "abc123", purgeAt = 1567342800`,
+		expand(claim.UpdateExpression, claim.ExpressionAttributeNames, claim.ExpressionAttributeValues))
+}
+
+func TestDynamoDBRepositoryRestoresDeletedUsers(t *testing.T) {
+	ddb, requests := scriptedDynamoDB(t, ok(deletedUser(2)), ok(`{}`))
+	repo := NewDynamoDBRepository(ddb, "users")
+
+	restored, err := repo.Restore(context.Background(), "abc123", 2, now)
+	assert.NoError(t, err)
+	assert.Nil(t, restored.DeletedAt)
+	assert.Equal(t, int64(3), restored.Version)
+
+	input := &dynamodb.TransactWriteItemsInput{}
+	requests()[1].decode(t, input)
+	assert.Len(t, input.TransactItems, 2)
+
+	user := input.TransactItems[0].Update
+	assert.Equal(t,
+		"((attribute_exists (id)) AND (version = 2)) AND (attribute_exists (deletedAt))",
+		expand(user.ConditionExpression, user.ExpressionAttributeNames, user.ExpressionAttributeValues))
+	assert.Equal(t,
+		`REMOVE deletedAt, purgeAt
+SET updatedAt = "2019-09-01T12:00:00Z", version = if_not_exists(version, 0) + 1`,
+		expand(user.UpdateExpression, user.ExpressionAttributeNames, user.ExpressionAttributeValues))
+
+	claim := input.TransactItems[1].Update
+	assert.Equal(t,
+		`(attribute_not_exists (id)) OR (userId = "abc123")`,
+		expand(claim.ConditionExpression, claim.ExpressionAttributeNames, claim.ExpressionAttributeValues))
+}
+
+func TestDynamoDBRepositoryMapsWriteErrors(t *testing.T) {
+	age := uint32(31)
+	email := "new@test.com"
+	conditionFailed := fails(dynamodb.ErrCodeConditionalCheckFailedException, "The conditional request failed")
+	throttled := fails(dynamodb.ErrCodeProvisionedThroughputExceededException, "slow down")
+
+	update := func(repo *DynamoDBRepository) error {
+		_, err := repo.Update(context.Background(), "abc123", &UpdateUser{Age: &age, Version: 2})
+		return err
+	}
+	updateEmail := func(repo *DynamoDBRepository) error {
+		_, err := repo.Update(context.Background(), "abc123", &UpdateUser{Email: &email, Version: 1})
+		return err
+	}
+	create := func(repo *DynamoDBRepository) error {
+		return repo.Create(context.Background(), &User{ID: "abc123", Email: "test@test.com"})
+	}
+	remove := func(repo *DynamoDBRepository) error {
+		return repo.Delete(context.Background(), "abc123", 1, now, now.Add(time.Hour))
+	}
+	restore := func(repo *DynamoDBRepository) error {
+		_, err := repo.Restore(context.Background(), "abc123", 2, now)
+		return err
+	}
+
+	cases := []struct {
+		name    string
+		write   func(repo *DynamoDBRepository) error
+		replies []reply
+		want    error
+	}{
+		{"update at an old version", update, []reply{conditionFailed, ok(storedUser(3))}, ErrVersionMismatch},
+		{"update of an unknown user", update, []reply{conditionFailed, ok(`{}`)}, ErrNotFound},
+		{"update of a deleted user", update, []reply{conditionFailed, ok(deletedUser(3))}, ErrNotFound},
+		{"update throttled", update, []reply{throttled}, nil},
+		{"update to a taken email", updateEmail, []reply{ok(storedUser(1)), cancelled("None", "None", "ConditionalCheckFailed")}, ErrEmailTaken},
+		{"update of the email at an old version", updateEmail, []reply{ok(storedUser(2))}, ErrVersionMismatch},
+		{"update of the email raced", updateEmail, []reply{ok(storedUser(1)), cancelled("ConditionalCheckFailed", "None", "None"), ok(storedUser(2))}, ErrVersionMismatch},
+		{"update of the email throttled", updateEmail, []reply{ok(storedUser(1)), cancelled("ThrottlingError", "None", "None")}, nil},
+		{"create of an existing user", create, []reply{cancelled("ConditionalCheckFailed", "None")}, ErrUserExists},
+		{"create with a taken email", create, []reply{cancelled("None", "ConditionalCheckFailed")}, ErrEmailTaken},
+		{"create throttled", create, []reply{throttled}, nil},
+		{"delete at an old version", remove, []reply{ok(storedUser(2))}, ErrVersionMismatch},
+		{"delete of a deleted user", remove, []reply{ok(deletedUser(2))}, ErrNotFound},
+		{"delete raced", remove, []reply{ok(storedUser(1)), cancelled("ConditionalCheckFailed", "None"), ok(storedUser(2))}, ErrVersionMismatch},
+		{"delete raced by a delete", remove, []reply{ok(storedUser(1)), cancelled("ConditionalCheckFailed", "None"), ok(deletedUser(2))}, ErrNotFound},
+		{"restore of a live user", restore, []reply{ok(storedUser(2))}, ErrNotFound},
+		{"restore at an old version", restore, []reply{ok(deletedUser(3))}, ErrVersionMismatch},
+		{"restore raced", restore, []reply{ok(deletedUser(2)), cancelled("ConditionalCheckFailed", "None")}, ErrVersionMismatch},
+		{"restore with a reclaimed email", restore, []reply{ok(deletedUser(2)), cancelled("None", "ConditionalCheckFailed")}, ErrEmailTaken},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ddb, _ := scriptedDynamoDB(t, c.replies...)
+			err := c.write(NewDynamoDBRepository(ddb, "users"))
+
+			// Throttling is passed on, for clients to back off
+			if c.want == nil {
+				assert.Equal(t, apperrors.Throttled, apperrors.CodeOf(err))
+				return
+			}
+			assert.Equal(t, c.want, err)
+		})
+	}
+}
+
+func TestDynamoDBRepositoryScansUntilThePageIsFull(t *testing.T) {
+	ddb, requests := scriptedDynamoDB(t,
+		ok(`{"Items":[{"id":{"S":"a"}}],"LastEvaluatedKey":{"id":{"S":"email#b@test.com"}}}`),
+		ok(`{"Items":[],"LastEvaluatedKey":{"id":{"S":"c"}}}`),
+		ok(`{"Items":[{"id":{"S":"d"}}],"LastEvaluatedKey":{"id":{"S":"d"}}}`),
+	)
+	repo := NewDynamoDBRepository(ddb, "users")
+
+	users, next, err := repo.GetAll(context.Background(), 2, encodeCursor("0"))
+	assert.NoError(t, err)
+	assert.Len(t, users, 2)
+	assert.Equal(t, "a", users[0].ID)
+	assert.Equal(t, "d", users[1].ID)
+	assert.Equal(t, encodeCursor("d"), next)
+
+	var starts []string
+	var limits []int64
+	for _, req := range requests() {
+		input := &dynamodb.ScanInput{}
+		req.decode(t, input)
+		assert.Equal(t,
+			`(NOT (begins_with (id, "email#"))) AND (attribute_not_exists (deletedAt))`,
+			expand(input.FilterExpression, input.ExpressionAttributeNames, input.ExpressionAttributeValues))
+		starts = append(starts, aws.StringValue(input.ExclusiveStartKey["id"].S))
+		limits = append(limits, aws.Int64Value(input.Limit))
+	}
+
+	// Each scan carries on from the last, for what's left of the page
+	assert.Equal(t, []string{"0", "email#b@test.com", "c"}, starts)
+	assert.Equal(t, []int64{2, 1, 1}, limits)
+}
+
+func TestDynamoDBRepositoryScansToTheEndOfTheTable(t *testing.T) {
+	ddb, _ := scriptedDynamoDB(t,
+		ok(`{"Items":[{"id":{"S":"a"}}],"LastEvaluatedKey":{"id":{"S":"a"}}}`),
+		ok(`{"Items":[]}`),
+	)
+	repo := NewDynamoDBRepository(ddb, "users")
+
+	users, next, err := repo.GetAll(context.Background(), 2, "")
+	assert.NoError(t, err)
+	assert.Len(t, users, 1)
+	assert.Equal(t, "", next)
+}
@@ -0,0 +1,41 @@
+package users
+
+import (
+	"github.com/EwanValentine/serverless-api-example/pkg/tracing"
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TraceDynamoDB starts a span for each request made with the client,
+// as a child of the span in the request's context, covering any retries
+func TraceDynamoDB(c *client.Client) {
+	c.Handlers.Validate.PushFront(func(r *request.Request) {
+		ctx, _ := otel.Tracer(tracing.Name).Start(r.Context(), "DynamoDB."+r.Operation.Name,
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(
+				semconv.DBSystemDynamoDB,
+				semconv.DBOperationKey.String(r.Operation.Name),
+				semconv.RPCSystemKey.String("aws-api"),
+				semconv.RPCServiceKey.String("DynamoDB"),
+				semconv.RPCMethodKey.String(r.Operation.Name),
+			),
+		)
+		r.SetContext(ctx)
+	})
+
+	c.Handlers.Complete.PushBack(func(r *request.Request) {
+		span := trace.SpanFromContext(r.Context())
+		if r.HTTPResponse != nil {
+			span.SetAttributes(semconv.HTTPStatusCodeKey.Int(r.HTTPResponse.StatusCode))
+		}
+		if r.Error != nil {
+			span.RecordError(r.Error)
+			span.SetStatus(codes.Error, r.Error.Error())
+		}
+		span.End()
+	})
+}
@@ -0,0 +1,74 @@
+package users
+
+import (
+	"context"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"testing"
+)
+
+// recordSpans ended during the test
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+	return recorder
+}
+
+func TestTracingSpansDynamoDBWithinEachCall(t *testing.T) {
+	recorder := recordSpans(t)
+	ddb := fakeDynamoDB(t)
+	InstrumentDynamoDB(ddb.Client, prometheus.NewRegistry())
+	TraceDynamoDB(ddb.Client)
+	p := &Pipeline{
+		Usecase:      &Usecase{Repository: NewDynamoDBRepository(ddb, "users")},
+		Interceptors: []Interceptor{Tracing()},
+	}
+
+	ctx := context.Background()
+	p.Get(ctx, "abc123")
+	_, _, err := p.GetAll(ctx, 10, "")
+	assert.Error(t, err)
+
+	spans := recorder.Ended()
+	var names []string
+	for _, span := range spans {
+		names = append(names, span.Name())
+	}
+	assert.Equal(t, []string{"DynamoDB.GetItem", "users.get", "DynamoDB.Scan", "users.getAll"}, names)
+
+	assert.Equal(t, spans[1].SpanContext().SpanID(), spans[0].Parent().SpanID())
+	assert.Equal(t, spans[3].SpanContext().SpanID(), spans[2].Parent().SpanID())
+	assert.Equal(t, codes.Unset, spans[0].Status().Code)
+	assert.Equal(t, codes.Error, spans[2].Status().Code)
+}
+
+func TestTracingOnlyFailsSpansOnInternalErrors(t *testing.T) {
+	recorder := recordSpans(t)
+	p := &Pipeline{
+		Usecase:      &Usecase{Repository: NewMemoryRepository()},
+		Interceptors: []Interceptor{Tracing()},
+	}
+
+	_, err := p.Get(context.Background(), "abc123")
+	assert.True(t, IsNotFound(err))
+
+	spans := recorder.Ended()
+	assert.Len(t, spans, 1)
+	assert.Equal(t, codes.Unset, spans[0].Status().Code)
+
+	attributes := map[string]string{}
+	for _, kv := range spans[0].Attributes() {
+		attributes[string(kv.Key)] = kv.Value.Emit()
+	}
+	assert.Equal(t, map[string]string{
+		"users.operation": "get",
+		"users.id":        "abc123",
+		"users.outcome":   "not_found",
+	}, attributes)
+}
@@ -1,16 +1,68 @@
 package users
 
-// User -
+import (
+	"strings"
+	"time"
+)
+
+// User - fields tagged with pii are personal information,
+// which is redacted from logs, see the redact package
 type User struct {
 	ID    string `json:"id"`
-	Email string `json:"email" validate:"email,required"`
-	Name  string `json:"name" validate:"required,gte=1,lte=50"`
+	Email string `json:"email" validate:"email,required" pii:"email"`
+	Name  string `json:"name" validate:"required,gte=1,lte=50" pii:"name"`
 	Age   uint32 `json:"age" validate:"required,gte=0,lte=130"`
+
+	// Version goes up by one on every write
+	Version int64 `json:"version"`
+
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+
+	// DeletedAt is set once the user is deleted, they're then
+	// hidden until they're either restored or purged for good
+	DeletedAt *time.Time `json:"deletedAt,omitempty" dynamodbav:"deletedAt,omitempty,unixtime"`
 }
 
-// UpdateUser -
+// UpdateUser is a partial update, only the
+// fields which are present (non-nil) are changed
 type UpdateUser struct {
-	Email string `json:"email"`
-	Name  string `json:"name" validate:"gte=1,lte=50"`
-	Age   uint32 `json:"age" validate:"gte=0,lte=130"`
+	Email *string `json:"email,omitempty" validate:"omitempty,email" pii:"email"`
+	Name  *string `json:"name,omitempty" validate:"omitempty,gte=1,lte=50" pii:"name"`
+	Age   *uint32 `json:"age,omitempty" validate:"omitempty,gte=0,lte=130"`
+
+	// Version the update was based on, the update fails with
+	// ErrVersionMismatch if the user has moved on since. Zero skips the check.
+	Version int64 `json:"-"`
+
+	// UpdatedAt is set by the usecase
+	UpdatedAt time.Time `json:"-"`
+}
+
+// apply the fields present on the update to the user, as a new version
+func (u *User) apply(update *UpdateUser) {
+	if update.Name != nil {
+		u.Name = *update.Name
+	}
+	if update.Age != nil {
+		u.Age = *update.Age
+	}
+	if update.Email != nil {
+		u.Email = *update.Email
+	}
+	u.UpdatedAt = update.UpdatedAt
+	u.Version++
+}
+
+// Page of users, as returned to clients, with a link
+// to the next page when there are more users to fetch
+type Page struct {
+	Users []*User `json:"users"`
+	Next  string  `json:"next,omitempty"`
+}
+
+// normalizeEmail is the form emails are compared in,
+// so case doesn't allow the same address twice
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
 }
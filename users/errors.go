@@ -0,0 +1,34 @@
+package users
+
+import (
+	"github.com/EwanValentine/serverless-api-example/pkg/apperrors"
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrNotFound is returned when a user doesn't exist
+	ErrNotFound = apperrors.New(apperrors.NotFound, "user not found")
+
+	// ErrUserExists is returned when creating a user whose ID is already taken
+	ErrUserExists = apperrors.New(apperrors.Conflict, "user already exists")
+
+	// ErrEmailTaken is returned when another user already has the email
+	ErrEmailTaken = apperrors.New(apperrors.Conflict, "email already in use")
+
+	// ErrVersionMismatch is returned when updating a stale version of a user
+	ErrVersionMismatch = apperrors.New(apperrors.PreconditionFailed, "user has been modified")
+
+	// ErrInvalidCursor is returned for page cursors we didn't issue
+	ErrInvalidCursor = apperrors.New(apperrors.Validation, "invalid page cursor")
+
+	// ErrInvalidLimit is returned for page sizes below zero
+	ErrInvalidLimit = apperrors.New(apperrors.Validation, "invalid page size")
+
+	// ErrMalformed is returned for request bodies which aren't valid JSON
+	ErrMalformed = apperrors.New(apperrors.Validation, "malformed request body")
+)
+
+// IsNotFound reports whether err, or the error it wraps, is ErrNotFound
+func IsNotFound(err error) bool {
+	return errors.Cause(err) == ErrNotFound
+}
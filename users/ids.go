@@ -0,0 +1,88 @@
+package users
+
+import (
+	"crypto/rand"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/oklog/ulid"
+	"github.com/segmentio/ksuid"
+	"io"
+	"sync"
+	"time"
+)
+
+const (
+	// IDStrategyUUID generates random, version 4, UUIDs
+	IDStrategyUUID = "uuid"
+
+	// IDStrategyULID generates ULIDs, which sort in creation order
+	IDStrategyULID = "ulid"
+
+	// IDStrategyKSUID generates KSUIDs, which sort in creation order
+	IDStrategyKSUID = "ksuid"
+)
+
+// IDGenerator creates the IDs of new users
+type IDGenerator interface {
+	NewID() string
+}
+
+// IDGeneratorFunc lets a plain function, such as
+// a deterministic one in tests, generate IDs
+type IDGeneratorFunc func() string
+
+// NewID -
+func (f IDGeneratorFunc) NewID() string {
+	return f()
+}
+
+// NewIDGenerator creates the generator for a strategy,
+// an empty strategy defaults to UUIDs.
+func NewIDGenerator(strategy string) (IDGenerator, error) {
+	switch strategy {
+	case "", IDStrategyUUID:
+		return UUIDGenerator{}, nil
+	case IDStrategyULID:
+		return NewULIDGenerator(), nil
+	case IDStrategyKSUID:
+		return KSUIDGenerator{}, nil
+	default:
+		return nil, fmt.Errorf("unknown id strategy %q", strategy)
+	}
+}
+
+// UUIDGenerator -
+type UUIDGenerator struct{}
+
+// NewID -
+func (UUIDGenerator) NewID() string {
+	return uuid.New().String()
+}
+
+// ULIDGenerator creates ULIDs, which are monotonic
+// even when created within the same millisecond
+type ULIDGenerator struct {
+	mu      sync.Mutex
+	entropy io.Reader
+}
+
+// NewULIDGenerator -
+func NewULIDGenerator() *ULIDGenerator {
+	return &ULIDGenerator{entropy: ulid.Monotonic(rand.Reader, 0)}
+}
+
+// NewID -
+func (g *ULIDGenerator) NewID() string {
+	// Monotonic entropy isn't safe for concurrent use
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return ulid.MustNew(ulid.Timestamp(time.Now()), g.entropy).String()
+}
+
+// KSUIDGenerator -
+type KSUIDGenerator struct{}
+
+// NewID -
+func (KSUIDGenerator) NewID() string {
+	return ksuid.New().String()
+}
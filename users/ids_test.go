@@ -0,0 +1,35 @@
+package users
+
+import (
+	"github.com/stretchr/testify/assert"
+	"sort"
+	"testing"
+)
+
+func TestIDGeneratorStrategies(t *testing.T) {
+	for strategy, length := range map[string]int{
+		"":              36,
+		IDStrategyUUID:  36,
+		IDStrategyULID:  26,
+		IDStrategyKSUID: 27,
+	} {
+		ids, err := NewIDGenerator(strategy)
+		assert.NoError(t, err)
+
+		id := ids.NewID()
+		assert.Len(t, id, length, strategy)
+		assert.NotEqual(t, id, ids.NewID(), strategy)
+	}
+
+	_, err := NewIDGenerator("serial")
+	assert.Error(t, err)
+}
+
+func TestULIDsSortInCreationOrder(t *testing.T) {
+	ids := NewULIDGenerator()
+	created := make([]string, 100)
+	for i := range created {
+		created[i] = ids.NewID()
+	}
+	assert.True(t, sort.StringsAreSorted(created))
+}
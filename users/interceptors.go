@@ -0,0 +1,199 @@
+package users
+
+import (
+	"context"
+	"time"
+
+	"github.com/EwanValentine/serverless-api-example/pkg/apperrors"
+	"github.com/EwanValentine/serverless-api-example/pkg/logging"
+	"github.com/EwanValentine/serverless-api-example/pkg/metrics"
+	"github.com/EwanValentine/serverless-api-example/pkg/redact"
+	"github.com/EwanValentine/serverless-api-example/pkg/tracing"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+// Logging logs each call once it's done, with its operation, the
+// user's ID, how long it took and its outcome, which is ok or the
+// error code. The context's logger is used, if it carries one, see
+// the logging package. Personal information on the users passed in
+// is redacted from anything logged during the call.
+func Logging(logger *zap.Logger) Interceptor {
+	return func(ctx context.Context, call Call, next Invoker) (interface{}, error) {
+		l := logger
+		if carried, ok := logging.FromContext(ctx); ok {
+			l = carried
+		}
+
+		var known []string
+		for _, arg := range call.Args {
+			known = append(known, redact.Values(arg)...)
+		}
+		l = redact.Logger(l, known...)
+
+		start := time.Now()
+		result, err := next(logging.NewContext(ctx, l))
+
+		l = l.With(zap.String("operation", call.Operation))
+		defer l.Sync()
+
+		fields := []zap.Field{zap.Duration("duration", time.Since(start))}
+		if id := userID(call, result); id != "" {
+			fields = append(fields, zap.String("userId", id))
+		}
+		switch r := result.(type) {
+		case Listing:
+			fields = append(fields, zap.Int("users", len(r.Users)))
+		case int:
+			fields = append(fields, zap.Int("purged", r))
+		}
+
+		fields = append(fields, zap.String("outcome", outcome(err)))
+		if err == nil {
+			l.Info(call.Operation, fields...)
+			return result, err
+		}
+
+		code := apperrors.CodeOf(err)
+		fields = append(fields, zap.Error(err))
+
+		// Missing users, invalid input and the like are
+		// expected outcomes, not failures on our part
+		switch code {
+		case apperrors.Internal:
+			l.Error(call.Operation, fields...)
+		case apperrors.Throttled:
+			l.Warn(call.Operation, fields...)
+		default:
+			l.Info(call.Operation, fields...)
+		}
+		return result, err
+	}
+}
+
+// outcome of a call, ok or the error's code
+func outcome(err error) string {
+	if err == nil {
+		return "ok"
+	}
+	return string(apperrors.CodeOf(err))
+}
+
+// Metrics measures how long each call takes, by operation
+// and outcome, in a histogram registered with reg
+func Metrics(reg prometheus.Registerer) Interceptor {
+	duration := metrics.Register(reg, prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "users_operation_duration_seconds",
+		Help:    "How long calls to the users usecase took, by operation and outcome.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"operation", "outcome"})).(*prometheus.HistogramVec)
+
+	return measure(func(ctx context.Context, operation, outcome string, took time.Duration) {
+		duration.WithLabelValues(operation, outcome).Observe(took.Seconds())
+	})
+}
+
+// EMFMetrics logs how long each call takes, by operation
+// and outcome, in CloudWatch's embedded metric format
+func EMFMetrics(e *metrics.EMF) Interceptor {
+	dimensions := [][]string{{"Operation"}, {"Operation", "Outcome"}}
+	return measure(func(ctx context.Context, operation, outcome string, took time.Duration) {
+		e.Write(ctx, dimensions, map[string]string{"Operation": operation, "Outcome": outcome},
+			metrics.Metric{Name: "Calls", Unit: "Count", Value: 1},
+			metrics.Metric{Name: "OperationLatency", Unit: "Milliseconds", Value: float64(took) / float64(time.Millisecond)},
+		)
+	})
+}
+
+// measure how long each call takes, telling observe
+func measure(observe func(ctx context.Context, operation, outcome string, took time.Duration)) Interceptor {
+	return func(ctx context.Context, call Call, next Invoker) (interface{}, error) {
+		start := time.Now()
+		result, err := next(ctx)
+		observe(ctx, call.Operation, outcome(err), time.Since(start))
+		return result, err
+	}
+}
+
+// Tracing starts a span for each call, named after its operation,
+// which DynamoDB's spans are children of. Only internal errors mark
+// the span as failed, other outcomes are expected.
+func Tracing() Interceptor {
+	return func(ctx context.Context, call Call, next Invoker) (interface{}, error) {
+		ctx, span := otel.Tracer(tracing.Name).Start(ctx, "users."+call.Operation,
+			trace.WithAttributes(attribute.String("users.operation", call.Operation)),
+		)
+		defer span.End()
+
+		result, err := next(ctx)
+		if id := userID(call, result); id != "" {
+			span.SetAttributes(attribute.String("users.id", id))
+		}
+		span.SetAttributes(attribute.String("users.outcome", outcome(err)))
+		if err != nil && apperrors.CodeOf(err) == apperrors.Internal {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, "internal error")
+		}
+		return result, err
+	}
+}
+
+// userID the call was about, which is known up front,
+// other than for new users, or users looked up by email
+func userID(call Call, result interface{}) string {
+	if user, ok := result.(*User); ok && user != nil {
+		return user.ID
+	}
+
+	switch call.Operation {
+	case OperationGet, OperationUpdate, OperationDelete, OperationRestore:
+		id, _ := call.Args[0].(string)
+		return id
+	}
+	return ""
+}
+
+// Timeout gives each call as long as timeout says its operation
+// gets, calls which fail once it's up have the Timeout code
+func Timeout(timeout func(operation string) time.Duration) Interceptor {
+	return func(ctx context.Context, call Call, next Invoker) (interface{}, error) {
+		ctx, cancel := context.WithTimeout(ctx, timeout(call.Operation))
+		defer cancel()
+
+		result, err := next(ctx)
+		if err != nil && ctx.Err() == context.DeadlineExceeded {
+			return result, apperrors.Wrap(err, apperrors.Timeout, "the request took too long")
+		}
+		return result, err
+	}
+}
+
+// after waits for the backoff, tests replace it to see the delays asked for
+var after = time.After
+
+// Retry calls which are throttled, up to attempts times in all,
+// backing off for twice as long after each attempt, starting at
+// backoff. Throttled calls haven't done anything, so it's safe
+// to retry writes too.
+func Retry(attempts int, backoff time.Duration) Interceptor {
+	return func(ctx context.Context, call Call, next Invoker) (interface{}, error) {
+		delay := backoff
+		for attempt := 1; ; attempt++ {
+			result, err := next(ctx)
+			if err == nil || attempt >= attempts || apperrors.CodeOf(err) != apperrors.Throttled {
+				return result, err
+			}
+
+			select {
+			case <-ctx.Done():
+				return result, err
+			case <-after(delay):
+			}
+			delay *= 2
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package users
+
+import (
+	"context"
+	"github.com/EwanValentine/serverless-api-example/pkg/apperrors"
+	"github.com/EwanValentine/serverless-api-example/pkg/logging"
+	"github.com/EwanValentine/serverless-api-example/pkg/redact"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"testing"
+	"time"
+)
+
+func TestLoggingLogsTheOutcome(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	p := &Pipeline{
+		Usecase:      &Usecase{Repository: NewMemoryRepository()},
+		Interceptors: []Interceptor{Logging(zap.NewNop())},
+	}
+
+	// The context's logger is used, with whatever the deliveries added
+	ctx := logging.With(logging.NewContext(context.Background(), zap.New(core)), zap.String("requestId", "req-1"))
+	p.Get(ctx, "abc123")
+	p.Create(ctx, &User{Name: "Ewan", Email: "test@test.com", Age: 30})
+
+	entries := logs.AllUntimed()
+	assert.Len(t, entries, 2)
+
+	missing := entries[0].ContextMap()
+	assert.Equal(t, "get", missing["operation"])
+	assert.Equal(t, "abc123", missing["userId"])
+	assert.Equal(t, "not_found", missing["outcome"])
+	assert.Equal(t, "req-1", missing["requestId"])
+	assert.Contains(t, missing, "duration")
+
+	created := entries[1].ContextMap()
+	assert.Equal(t, "create", created["operation"])
+	assert.Equal(t, "ok", created["outcome"])
+	assert.NotEmpty(t, created["userId"])
+}
+
+func TestLoggingFallsBackToItsLogger(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	p := &Pipeline{
+		Usecase:      &Usecase{Repository: NewMemoryRepository()},
+		Interceptors: []Interceptor{Logging(zap.New(core))},
+	}
+
+	p.Delete(context.Background(), "abc123", 0)
+	assert.Equal(t, 1, logs.FilterField(zap.String("operation", "delete")).Len())
+}
+
+func TestLoggingRedactsTheUser(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	echo := func(ctx context.Context, call Call, next Invoker) (interface{}, error) {
+		logging.Logger(ctx).Info("can't rename ewan@test.com to Ewan Valentine")
+		return next(ctx)
+	}
+	p := &Pipeline{
+		Usecase:      &Usecase{Repository: NewMemoryRepository()},
+		Interceptors: []Interceptor{Logging(zap.New(redact.Core(core))), echo},
+	}
+
+	// Anything logged about the update, such as an
+	// error which echoes it back, has the user redacted
+	name := "Ewan Valentine"
+	p.Update(context.Background(), "abc123", &UpdateUser{Name: &name})
+	assert.Equal(t, "can't rename e***@test.com to E*** V***", logs.All()[0].Message)
+}
+
+func TestTimeoutBoundsEachOperation(t *testing.T) {
+	timeouts := map[string]time.Duration{OperationGet: time.Millisecond, OperationPurge: time.Hour}
+	wait := func(ctx context.Context, call Call, next Invoker) (interface{}, error) {
+		if call.Operation == OperationGet {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+		deadline, _ := ctx.Deadline()
+		assert.WithinDuration(t, time.Now().Add(time.Hour), deadline, time.Minute)
+		return next(ctx)
+	}
+	p := &Pipeline{
+		Usecase: &Usecase{Repository: NewMemoryRepository()},
+		Interceptors: []Interceptor{Timeout(func(operation string) time.Duration {
+			return timeouts[operation]
+		}), wait},
+	}
+
+	_, err := p.Get(context.Background(), "abc123")
+	assert.Equal(t, apperrors.Timeout, apperrors.CodeOf(err))
+
+	_, err = p.Purge(context.Background())
+	assert.NoError(t, err)
+}
+
+func TestRetryRetriesThrottledCalls(t *testing.T) {
+	attempts := 0
+	throttle := func(times int) Interceptor {
+		return func(ctx context.Context, call Call, next Invoker) (interface{}, error) {
+			attempts++
+			if attempts <= times {
+				return nil, apperrors.New(apperrors.Throttled, "slow down")
+			}
+			return next(ctx)
+		}
+	}
+	retrying := func(times int) *Pipeline {
+		attempts = 0
+		return &Pipeline{
+			Usecase:      &Usecase{Repository: NewMemoryRepository()},
+			Interceptors: []Interceptor{Retry(3, time.Millisecond), throttle(times)},
+		}
+	}
+
+	_, err := retrying(2).Purge(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 3, attempts)
+
+	_, err = retrying(3).Purge(context.Background())
+	assert.Equal(t, apperrors.Throttled, apperrors.CodeOf(err))
+	assert.Equal(t, 3, attempts)
+
+	// Other errors aren't worth retrying
+	_, err = retrying(0).Get(context.Background(), "abc123")
+	assert.True(t, IsNotFound(err))
+	assert.Equal(t, 1, attempts)
+}
+
+func TestRetryBacksOffAfreshForEachCall(t *testing.T) {
+	var delays []time.Duration
+	after = func(d time.Duration) <-chan time.Time {
+		delays = append(delays, d)
+		return time.After(0)
+	}
+	t.Cleanup(func() { after = time.After })
+
+	backoff := time.Millisecond * 50
+	retry := Retry(3, backoff)
+	throttled := func(ctx context.Context) (interface{}, error) {
+		return nil, apperrors.New(apperrors.Throttled, "slow down")
+	}
+
+	// Each call doubles the backoff as it goes, starting from the
+	// base backoff, rather than where the call before it left off
+	for i := 0; i < 2; i++ {
+		retry(context.Background(), Call{Operation: OperationPurge}, throttled)
+	}
+	assert.Equal(t, []time.Duration{backoff, backoff * 2, backoff, backoff * 2}, delays)
+}
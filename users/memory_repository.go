@@ -0,0 +1,195 @@
+package users
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryRepository is an in-memory, thread-safe repository, useful
+// for running the service locally and in tests without AWS.
+type MemoryRepository struct {
+	mu    sync.RWMutex
+	users map[string]User
+
+	// emails maps each normalised email to the user who claimed it
+	emails map[string]string
+}
+
+// NewMemoryRepository -
+func NewMemoryRepository() *MemoryRepository {
+	return &MemoryRepository{
+		users:  make(map[string]User),
+		emails: make(map[string]string),
+	}
+}
+
+// Get a user, deleted users are hidden
+func (r *MemoryRepository) Get(ctx context.Context, id string) (*User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	user, ok := r.users[id]
+	if !ok || user.DeletedAt != nil {
+		return nil, ErrNotFound
+	}
+	return &user, nil
+}
+
+// GetByEmail finds the user who claimed the email
+func (r *MemoryRepository) GetByEmail(ctx context.Context, email string) (*User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	user, ok := r.users[r.emails[normalizeEmail(email)]]
+	if !ok || user.DeletedAt != nil {
+		return nil, ErrNotFound
+	}
+	return &user, nil
+}
+
+// GetAll users, a page at a time, in ID order
+func (r *MemoryRepository) GetAll(ctx context.Context, limit int, cursor string) ([]*User, string, error) {
+	after := ""
+	if cursor != "" {
+		id, err := decodeCursor(cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		after = id
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	users := make([]*User, 0, len(r.users))
+	for _, user := range r.users {
+		if user.ID <= after || user.DeletedAt != nil {
+			continue
+		}
+		user := user
+		users = append(users, &user)
+	}
+
+	// Map iteration order is random, so keep scans stable
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].ID < users[j].ID
+	})
+
+	if limit <= 0 || len(users) <= limit {
+		return users, "", nil
+	}
+
+	users = users[:limit]
+	return users, encodeCursor(users[limit-1].ID), nil
+}
+
+// Update a user, only setting the fields which are present
+func (r *MemoryRepository) Update(ctx context.Context, id string, user *UpdateUser) (*User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	existing, ok := r.users[id]
+	if !ok || existing.DeletedAt != nil {
+		return nil, ErrNotFound
+	}
+
+	if user.Version != 0 && user.Version != existing.Version {
+		return nil, ErrVersionMismatch
+	}
+
+	if user.Email != nil {
+		email := normalizeEmail(*user.Email)
+		if owner, ok := r.emails[email]; ok && owner != id {
+			return nil, ErrEmailTaken
+		}
+
+		delete(r.emails, normalizeEmail(existing.Email))
+		r.emails[email] = id
+	}
+
+	existing.apply(user)
+	r.users[id] = existing
+	return &existing, nil
+}
+
+// Create a user, claiming their email
+func (r *MemoryRepository) Create(ctx context.Context, user *User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.users[user.ID]; ok {
+		return ErrUserExists
+	}
+
+	email := normalizeEmail(user.Email)
+	if _, ok := r.emails[email]; ok {
+		return ErrEmailTaken
+	}
+
+	r.users[user.ID] = *user
+	r.emails[email] = user.ID
+	return nil
+}
+
+// Delete a user, softly. They're hidden, and their email kept,
+// until they're restored or purged.
+func (r *MemoryRepository) Delete(ctx context.Context, id string, version int64, deletedAt, purgeAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, ok := r.users[id]
+	if !ok || user.DeletedAt != nil {
+		return ErrNotFound
+	}
+
+	if version != 0 && version != user.Version {
+		return ErrVersionMismatch
+	}
+
+	user.DeletedAt = &deletedAt
+	user.UpdatedAt = deletedAt
+	user.Version++
+	r.users[id] = user
+	return nil
+}
+
+// Restore a deleted user
+func (r *MemoryRepository) Restore(ctx context.Context, id string, version int64, restoredAt time.Time) (*User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, ok := r.users[id]
+	if !ok || user.DeletedAt == nil {
+		return nil, ErrNotFound
+	}
+
+	if version != 0 && version != user.Version {
+		return nil, ErrVersionMismatch
+	}
+
+	user.DeletedAt = nil
+	user.UpdatedAt = restoredAt
+	user.Version++
+	r.users[id] = user
+	return &user, nil
+}
+
+// Purge users deleted before the given time for good, releasing their emails
+func (r *MemoryRepository) Purge(ctx context.Context, before time.Time) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	purged := 0
+	for id, user := range r.users {
+		if user.DeletedAt == nil || !user.DeletedAt.Before(before) {
+			continue
+		}
+
+		delete(r.users, id)
+		delete(r.emails, normalizeEmail(user.Email))
+		purged++
+	}
+	return purged, nil
+}
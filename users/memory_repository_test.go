@@ -0,0 +1,249 @@
+package users
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestMemoryRepositoryCanCreateAndGet(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository()
+	expected := &User{ID: "abc123", Name: "Ewan", Email: "test@test.com", Age: 30}
+
+	err := repo.Create(ctx, expected)
+	assert.NoError(t, err)
+
+	user, err := repo.Get(ctx, "abc123")
+	assert.NoError(t, err)
+	assert.Equal(t, expected, user)
+
+	// Changes to the returned user must not leak into the store
+	user.Name = "changed"
+	user, _ = repo.Get(ctx, "abc123")
+	assert.Equal(t, "Ewan", user.Name)
+
+	_, err = repo.Get(ctx, "unknown")
+	assert.Equal(t, ErrNotFound, err)
+}
+
+func TestMemoryRepositoryWontOverwriteUsers(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository()
+	repo.Create(ctx, &User{ID: "abc123", Name: "Ewan", Email: "test@test.com"})
+
+	err := repo.Create(ctx, &User{ID: "abc123", Name: "Other", Email: "other@test.com"})
+	assert.Equal(t, ErrUserExists, err)
+
+	user, _ := repo.Get(ctx, "abc123")
+	assert.Equal(t, "Ewan", user.Name)
+
+	// The rejected user's email wasn't claimed
+	err = repo.Create(ctx, &User{ID: "def456", Email: "other@test.com"})
+	assert.NoError(t, err)
+}
+
+func TestMemoryRepositoryCanGetAll(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository()
+	repo.Create(ctx, &User{ID: "b", Name: "test2", Email: "b@test.com"})
+	repo.Create(ctx, &User{ID: "a", Name: "test1", Email: "a@test.com"})
+
+	users, next, err := repo.GetAll(ctx, 10, "")
+	assert.NoError(t, err)
+	assert.Len(t, users, 2)
+	assert.Equal(t, "a", users[0].ID)
+	assert.Equal(t, "b", users[1].ID)
+	assert.Empty(t, next)
+}
+
+func TestMemoryRepositoryCanPaginate(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository()
+	for _, id := range []string{"a", "b", "c"} {
+		repo.Create(ctx, &User{ID: id, Email: id + "@test.com"})
+	}
+
+	users, next, err := repo.GetAll(ctx, 2, "")
+	assert.NoError(t, err)
+	assert.Len(t, users, 2)
+	assert.NotEmpty(t, next)
+
+	users, next, err = repo.GetAll(ctx, 2, next)
+	assert.NoError(t, err)
+	assert.Len(t, users, 1)
+	assert.Equal(t, "c", users[0].ID)
+	assert.Empty(t, next)
+
+	_, _, err = repo.GetAll(ctx, 2, "not a cursor!")
+	assert.Equal(t, ErrInvalidCursor, err)
+}
+
+func TestMemoryRepositoryCanUpdate(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository()
+	repo.Create(ctx, &User{ID: "abc123", Name: "Ewan", Email: "test@test.com", Age: 30})
+
+	name, age := "new name", uint32(31)
+	updated, err := repo.Update(ctx, "abc123", &UpdateUser{Name: &name, Age: &age})
+	assert.NoError(t, err)
+
+	// Email was left out, so it's left as is
+	user, _ := repo.Get(ctx, "abc123")
+	assert.Equal(t, &User{ID: "abc123", Name: "new name", Email: "test@test.com", Age: 31, Version: 1}, user)
+	assert.Equal(t, user, updated)
+}
+
+func TestMemoryRepositoryChecksVersions(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository()
+	repo.Create(ctx, &User{ID: "abc123", Name: "Ewan", Email: "test@test.com", Version: 1})
+
+	name := "new name"
+	updated, err := repo.Update(ctx, "abc123", &UpdateUser{Name: &name, Version: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), updated.Version)
+
+	// Somebody else has written version 2 since
+	_, err = repo.Update(ctx, "abc123", &UpdateUser{Name: &name, Version: 1})
+	assert.Equal(t, ErrVersionMismatch, err)
+}
+
+func TestMemoryRepositoryCanDelete(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository()
+	repo.Create(ctx, &User{ID: "abc123", Name: "Ewan"})
+
+	now := time.Now()
+	err := repo.Delete(ctx, "abc123", 0, now, now.Add(time.Hour))
+	assert.NoError(t, err)
+
+	users, _, _ := repo.GetAll(ctx, 10, "")
+	assert.Len(t, users, 0)
+
+	_, err = repo.Get(ctx, "abc123")
+	assert.Equal(t, ErrNotFound, err)
+
+	err = repo.Delete(ctx, "abc123", 0, now, now.Add(time.Hour))
+	assert.Equal(t, ErrNotFound, err)
+}
+
+func TestMemoryRepositoryCanRestore(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository()
+	repo.Create(ctx, &User{ID: "abc123", Name: "Ewan", Email: "test@test.com", Version: 1})
+
+	now := time.Now()
+	repo.Delete(ctx, "abc123", 0, now, now.Add(time.Hour))
+
+	restored, err := repo.Restore(ctx, "abc123", 0, now.Add(time.Minute))
+	assert.NoError(t, err)
+	assert.Nil(t, restored.DeletedAt)
+	assert.Equal(t, now.Add(time.Minute), restored.UpdatedAt)
+	assert.Equal(t, int64(3), restored.Version)
+
+	user, err := repo.Get(ctx, "abc123")
+	assert.NoError(t, err)
+	assert.Equal(t, restored, user)
+}
+
+func TestMemoryRepositoryWontRestoreLiveUsers(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository()
+	repo.Create(ctx, &User{ID: "abc123", Name: "Ewan", Email: "test@test.com", Version: 1})
+
+	_, err := repo.Restore(ctx, "abc123", 0, time.Now())
+	assert.Equal(t, ErrNotFound, err)
+
+	user, _ := repo.Get(ctx, "abc123")
+	assert.Equal(t, int64(1), user.Version)
+	assert.True(t, user.UpdatedAt.IsZero())
+}
+
+func TestMemoryRepositoryCanPurge(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository()
+	repo.Create(ctx, &User{ID: "old", Email: "old@test.com"})
+	repo.Create(ctx, &User{ID: "recent", Email: "recent@test.com"})
+	repo.Create(ctx, &User{ID: "live", Email: "live@test.com"})
+
+	now := time.Now()
+	repo.Delete(ctx, "old", 0, now.Add(-time.Hour*2), now)
+	repo.Delete(ctx, "recent", 0, now, now.Add(time.Hour*2))
+
+	purged, err := repo.Purge(ctx, now.Add(-time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, purged)
+
+	_, err = repo.Restore(ctx, "old", 0, now)
+	assert.Equal(t, ErrNotFound, err)
+
+	// Purging releases the email
+	err = repo.Create(ctx, &User{ID: "new", Email: "old@test.com"})
+	assert.NoError(t, err)
+}
+
+func TestMemoryRepositoryWontWriteUnknownUsers(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository()
+
+	_, err := repo.Update(ctx, "unknown", &UpdateUser{})
+	assert.Equal(t, ErrNotFound, err)
+
+	err = repo.Delete(ctx, "unknown", 0, time.Now(), time.Now())
+	assert.Equal(t, ErrNotFound, err)
+
+	users, _, _ := repo.GetAll(ctx, 10, "")
+	assert.Len(t, users, 0)
+}
+
+func TestMemoryRepositoryEnforcesUniqueEmails(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository()
+	repo.Create(ctx, &User{ID: "a", Email: "test@test.com"})
+	repo.Create(ctx, &User{ID: "b", Email: "other@test.com"})
+
+	err := repo.Create(ctx, &User{ID: "c", Email: "TEST@test.com"})
+	assert.Equal(t, ErrEmailTaken, err)
+
+	taken := "test@test.com"
+	_, err = repo.Update(ctx, "b", &UpdateUser{Email: &taken})
+	assert.Equal(t, ErrEmailTaken, err)
+
+	// Releasing an email makes it available again
+	free := "free@test.com"
+	_, err = repo.Update(ctx, "a", &UpdateUser{Email: &free})
+	assert.NoError(t, err)
+
+	_, err = repo.Update(ctx, "b", &UpdateUser{Email: &taken})
+	assert.NoError(t, err)
+
+	user, err := repo.GetByEmail(ctx, "Test@Test.com")
+	assert.NoError(t, err)
+	assert.Equal(t, "b", user.ID)
+
+	repo.Delete(ctx, "b", 0, time.Now(), time.Now())
+	_, err = repo.GetByEmail(ctx, taken)
+	assert.Equal(t, ErrNotFound, err)
+}
+
+func TestMemoryRepositoryDeletesAndRestoresAtTheVersionGiven(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository()
+	repo.Create(ctx, &User{ID: "abc123", Name: "Ewan", Email: "test@test.com", Version: 1})
+
+	now := time.Now()
+	err := repo.Delete(ctx, "abc123", 2, now, now.Add(time.Hour))
+	assert.Equal(t, ErrVersionMismatch, err)
+
+	err = repo.Delete(ctx, "abc123", 1, now, now.Add(time.Hour))
+	assert.NoError(t, err)
+
+	_, err = repo.Restore(ctx, "abc123", 1, now)
+	assert.Equal(t, ErrVersionMismatch, err)
+
+	restored, err := repo.Restore(ctx, "abc123", 2, now)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), restored.Version)
+}
@@ -8,6 +8,7 @@ import (
 	context "context"
 	gomock "github.com/golang/mock/gomock"
 	reflect "reflect"
+	time "time"
 )
 
 // Mockrepository is a mock of repository interface
@@ -48,27 +49,44 @@ func (mr *MockrepositoryMockRecorder) Get(ctx, id interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*Mockrepository)(nil).Get), ctx, id)
 }
 
-// GetAll mocks base method
-func (m *Mockrepository) GetAll(ctx context.Context) ([]*User, error) {
+// GetByEmail mocks base method
+func (m *Mockrepository) GetByEmail(ctx context.Context, email string) (*User, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetAll", ctx)
-	ret0, _ := ret[0].([]*User)
+	ret := m.ctrl.Call(m, "GetByEmail", ctx, email)
+	ret0, _ := ret[0].(*User)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
+// GetByEmail indicates an expected call of GetByEmail
+func (mr *MockrepositoryMockRecorder) GetByEmail(ctx, email interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetByEmail", reflect.TypeOf((*Mockrepository)(nil).GetByEmail), ctx, email)
+}
+
+// GetAll mocks base method
+func (m *Mockrepository) GetAll(ctx context.Context, limit int, cursor string) ([]*User, string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAll", ctx, limit, cursor)
+	ret0, _ := ret[0].([]*User)
+	ret1, _ := ret[1].(string)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
 // GetAll indicates an expected call of GetAll
-func (mr *MockrepositoryMockRecorder) GetAll(ctx interface{}) *gomock.Call {
+func (mr *MockrepositoryMockRecorder) GetAll(ctx, limit, cursor interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAll", reflect.TypeOf((*Mockrepository)(nil).GetAll), ctx)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAll", reflect.TypeOf((*Mockrepository)(nil).GetAll), ctx, limit, cursor)
 }
 
 // Update mocks base method
-func (m *Mockrepository) Update(ctx context.Context, id string, user *UpdateUser) error {
+func (m *Mockrepository) Update(ctx context.Context, id string, user *UpdateUser) (*User, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "Update", ctx, id, user)
-	ret0, _ := ret[0].(error)
-	return ret0
+	ret0, _ := ret[0].(*User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
 // Update indicates an expected call of Update
@@ -92,15 +110,45 @@ func (mr *MockrepositoryMockRecorder) Create(ctx, user interface{}) *gomock.Call
 }
 
 // Delete mocks base method
-func (m *Mockrepository) Delete(ctx context.Context, id string) error {
+func (m *Mockrepository) Delete(ctx context.Context, id string, version int64, deletedAt, purgeAt time.Time) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Delete", ctx, id)
+	ret := m.ctrl.Call(m, "Delete", ctx, id, version, deletedAt, purgeAt)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // Delete indicates an expected call of Delete
-func (mr *MockrepositoryMockRecorder) Delete(ctx, id interface{}) *gomock.Call {
+func (mr *MockrepositoryMockRecorder) Delete(ctx, id, version, deletedAt, purgeAt interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*Mockrepository)(nil).Delete), ctx, id, version, deletedAt, purgeAt)
+}
+
+// Restore mocks base method
+func (m *Mockrepository) Restore(ctx context.Context, id string, version int64, restoredAt time.Time) (*User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Restore", ctx, id, version, restoredAt)
+	ret0, _ := ret[0].(*User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Restore indicates an expected call of Restore
+func (mr *MockrepositoryMockRecorder) Restore(ctx, id, version, restoredAt interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Restore", reflect.TypeOf((*Mockrepository)(nil).Restore), ctx, id, version, restoredAt)
+}
+
+// Purge mocks base method
+func (m *Mockrepository) Purge(ctx context.Context, before time.Time) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Purge", ctx, before)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Purge indicates an expected call of Purge
+func (mr *MockrepositoryMockRecorder) Purge(ctx, before interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*Mockrepository)(nil).Delete), ctx, id)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Purge", reflect.TypeOf((*Mockrepository)(nil).Purge), ctx, before)
 }
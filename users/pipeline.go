@@ -0,0 +1,129 @@
+package users
+
+import (
+	"context"
+)
+
+// Operations, as interceptors see them
+const (
+	OperationGet        = "get"
+	OperationGetByEmail = "getByEmail"
+	OperationGetAll     = "getAll"
+	OperationUpdate     = "update"
+	OperationCreate     = "create"
+	OperationDelete     = "delete"
+	OperationRestore    = "restore"
+	OperationPurge      = "purge"
+)
+
+// Call to the UserService, as interceptors see it
+type Call struct {
+	Operation string
+
+	// Args the operation was called with, after the context
+	Args []interface{}
+}
+
+// Listing is the result of GetAll, as interceptors see it
+type Listing struct {
+	Users  []*User
+	Cursor string
+}
+
+// Invoker carries on with a call, returning its result
+type Invoker func(ctx context.Context) (interface{}, error)
+
+// Interceptor is called around each call to the UserService. It can
+// do what it likes before and after calling next, which carries on
+// with the call, or not call it at all.
+type Interceptor func(ctx context.Context, call Call, next Invoker) (interface{}, error)
+
+// Pipeline is a UserService which passes each call through its
+// interceptors, in order, before the usecase handles it
+type Pipeline struct {
+	Usecase      UserService
+	Interceptors []Interceptor
+}
+
+// invoke the call, through each interceptor in turn
+func (p *Pipeline) invoke(ctx context.Context, call Call, handle Invoker) (interface{}, error) {
+	next := handle
+	for i := len(p.Interceptors) - 1; i >= 0; i-- {
+		interceptor, inner := p.Interceptors[i], next
+		next = func(ctx context.Context) (interface{}, error) {
+			return interceptor(ctx, call, inner)
+		}
+	}
+	return next(ctx)
+}
+
+// Get a single user
+func (p *Pipeline) Get(ctx context.Context, id string) (*User, error) {
+	result, err := p.invoke(ctx, Call{OperationGet, []interface{}{id}}, func(ctx context.Context) (interface{}, error) {
+		return p.Usecase.Get(ctx, id)
+	})
+	user, _ := result.(*User)
+	return user, err
+}
+
+// GetByEmail gets a single user by their email
+func (p *Pipeline) GetByEmail(ctx context.Context, email string) (*User, error) {
+	result, err := p.invoke(ctx, Call{OperationGetByEmail, []interface{}{email}}, func(ctx context.Context) (interface{}, error) {
+		return p.Usecase.GetByEmail(ctx, email)
+	})
+	user, _ := result.(*User)
+	return user, err
+}
+
+// GetAll gets a page of users
+func (p *Pipeline) GetAll(ctx context.Context, limit int, cursor string) ([]*User, string, error) {
+	result, err := p.invoke(ctx, Call{OperationGetAll, []interface{}{limit, cursor}}, func(ctx context.Context) (interface{}, error) {
+		users, next, err := p.Usecase.GetAll(ctx, limit, cursor)
+		return Listing{users, next}, err
+	})
+	listing, _ := result.(Listing)
+	return listing.Users, listing.Cursor, err
+}
+
+// Update a single user
+func (p *Pipeline) Update(ctx context.Context, id string, user *UpdateUser) (*User, error) {
+	result, err := p.invoke(ctx, Call{OperationUpdate, []interface{}{id, user}}, func(ctx context.Context) (interface{}, error) {
+		return p.Usecase.Update(ctx, id, user)
+	})
+	updated, _ := result.(*User)
+	return updated, err
+}
+
+// Create a single user, the result is the user as created
+func (p *Pipeline) Create(ctx context.Context, user *User) error {
+	_, err := p.invoke(ctx, Call{OperationCreate, []interface{}{user}}, func(ctx context.Context) (interface{}, error) {
+		return user, p.Usecase.Create(ctx, user)
+	})
+	return err
+}
+
+// Delete a single user
+func (p *Pipeline) Delete(ctx context.Context, id string, version int64) error {
+	_, err := p.invoke(ctx, Call{OperationDelete, []interface{}{id, version}}, func(ctx context.Context) (interface{}, error) {
+		return nil, p.Usecase.Delete(ctx, id, version)
+	})
+	return err
+}
+
+// Restore a single deleted user
+func (p *Pipeline) Restore(ctx context.Context, id string, version int64) (*User, error) {
+	result, err := p.invoke(ctx, Call{OperationRestore, []interface{}{id, version}}, func(ctx context.Context) (interface{}, error) {
+		return p.Usecase.Restore(ctx, id, version)
+	})
+	user, _ := result.(*User)
+	return user, err
+}
+
+// Purge deleted users, the result is how many were purged
+func (p *Pipeline) Purge(ctx context.Context) (int, error) {
+	result, err := p.invoke(ctx, Call{OperationPurge, nil}, func(ctx context.Context) (interface{}, error) {
+		return p.Usecase.Purge(ctx)
+	})
+	purged, _ := result.(int)
+	return purged, err
+}
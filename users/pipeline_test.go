@@ -0,0 +1,79 @@
+package users
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// recording interceptor, which notes the calls it sees as name:operation
+func recording(name string, calls *[]string) Interceptor {
+	return func(ctx context.Context, call Call, next Invoker) (interface{}, error) {
+		*calls = append(*calls, name+":"+call.Operation)
+		return next(ctx)
+	}
+}
+
+func TestPipelineCallsInterceptorsInOrder(t *testing.T) {
+	var calls []string
+	p := &Pipeline{
+		Usecase:      &Usecase{Repository: NewMemoryRepository()},
+		Interceptors: []Interceptor{recording("first", &calls), recording("second", &calls)},
+	}
+
+	p.Get(context.Background(), "abc123")
+	p.Purge(context.Background())
+	assert.Equal(t, []string{"first:get", "second:get", "first:purge", "second:purge"}, calls)
+}
+
+func TestPipelinePassesResultsThrough(t *testing.T) {
+	var seen []Call
+	var results []interface{}
+	p := &Pipeline{
+		Usecase: &Usecase{Repository: NewMemoryRepository(), IDs: IDGeneratorFunc(func() string { return "abc123" })},
+		Interceptors: []Interceptor{func(ctx context.Context, call Call, next Invoker) (interface{}, error) {
+			result, err := next(ctx)
+			seen = append(seen, call)
+			results = append(results, result)
+			return result, err
+		}},
+	}
+
+	ctx := context.Background()
+	user := &User{Name: "Ewan", Email: "test@test.com", Age: 30}
+	assert.NoError(t, p.Create(ctx, user))
+
+	fetched, err := p.Get(ctx, "abc123")
+	assert.NoError(t, err)
+	assert.Equal(t, user, fetched)
+
+	users, cursor, err := p.GetAll(ctx, 10, "")
+	assert.NoError(t, err)
+	assert.Equal(t, []*User{user}, users)
+	assert.Empty(t, cursor)
+
+	_, err = p.Restore(ctx, "unknown", 0)
+	assert.True(t, IsNotFound(err))
+
+	assert.Equal(t, []Call{
+		{OperationCreate, []interface{}{user}},
+		{OperationGet, []interface{}{"abc123"}},
+		{OperationGetAll, []interface{}{10, ""}},
+		{OperationRestore, []interface{}{"unknown", int64(0)}},
+	}, seen)
+	assert.Equal(t, user, results[0])
+	assert.Equal(t, Listing{Users: []*User{user}}, results[2])
+}
+
+func TestInterceptorsCanShortCircuit(t *testing.T) {
+	p := &Pipeline{
+		Usecase: &Usecase{Repository: NewMemoryRepository()},
+		Interceptors: []Interceptor{func(ctx context.Context, call Call, next Invoker) (interface{}, error) {
+			return &User{ID: "cached"}, nil
+		}},
+	}
+
+	user, err := p.Get(context.Background(), "abc123")
+	assert.NoError(t, err)
+	assert.Equal(t, "cached", user.ID)
+}
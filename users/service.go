@@ -2,26 +2,118 @@ package users
 
 import (
 	"context"
+	"github.com/EwanValentine/serverless-api-example/pkg/metrics"
+	"github.com/EwanValentine/serverless-api-example/pkg/redact"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
 	"github.com/aws/aws-xray-sdk-go/xray"
+	"github.com/prometheus/client_golang/prometheus"
 	"go.uber.org/zap"
 	"os"
+	"strconv"
+	"time"
 )
 
+const (
+	// DatastoreDynamoDB persists users in the DynamoDB table named by TABLE_NAME
+	DatastoreDynamoDB = "dynamodb"
+
+	// DatastoreMemory keeps users in memory, for local development and tests
+	DatastoreMemory = "memory"
+
+	// DefaultRetryAttempts is how many times throttled calls are tried
+	DefaultRetryAttempts = 3
+
+	retryBackoff = time.Millisecond * 50
+)
+
+// LambdaMetrics are logged to stdout for CloudWatch on lambda,
+// where there's nothing to scrape Prometheus' metrics from
+var LambdaMetrics = metrics.NewEMF(os.Stdout, "serverless-api-example")
+
 // UseService is the top level signature of this service
 type UserService interface {
 	Get(ctx context.Context, id string) (*User, error)
-	GetAll(ctx context.Context) ([]*User, error)
-	Update(ctx context.Context, id string, user *UpdateUser) error
+	GetByEmail(ctx context.Context, email string) (*User, error)
+	GetAll(ctx context.Context, limit int, cursor string) ([]*User, string, error)
+	Update(ctx context.Context, id string, user *UpdateUser) (*User, error)
 	Create(ctx context.Context, user *User) error
-	Delete(ctx context.Context, id string) error
+	Delete(ctx context.Context, id string, version int64) error
+	Restore(ctx context.Context, id string, version int64) (*User, error)
+	Purge(ctx context.Context) (int, error)
 }
 
 // Init sets up an instance of this domains
-// usecase, pre-configured with the dependencies.
+// usecase, pre-configured with the dependencies,
+// as the environment says, see the README.
 func Init(integration bool) (UserService, error) {
+	repository, err := newRepository(integration)
+	if err != nil {
+		return nil, err
+	}
+
+	ids, err := NewIDGenerator(os.Getenv("ID_STRATEGY"))
+	if err != nil {
+		return nil, err
+	}
+
+	var retention time.Duration
+	if r := os.Getenv("RETENTION"); r != "" {
+		if retention, err = time.ParseDuration(r); err != nil {
+			return nil, err
+		}
+	}
+
+	mode, err := redact.ParseMode(os.Getenv("PII_REDACTION"))
+	if err != nil {
+		return nil, err
+	}
+	redact.SetMode(mode)
+
+	// Deliveries log with the global logger, adding what they
+	// know about each request, see the logging package
+	logger, _ := zap.NewProduction(zap.WrapCore(redact.Core))
+	zap.ReplaceGlobals(logger)
+
+	attempts := DefaultRetryAttempts
+	if a := os.Getenv("RETRY_ATTEMPTS"); a != "" {
+		if attempts, err = strconv.Atoi(a); err != nil {
+			return nil, err
+		}
+	}
+
+	// Interceptors are called in order, each around the next. Retries
+	// are logged and measured as one call, and all happen within the
+	// request's timeout, which the deliveries set, see api.WithTimeouts.
+	// Metrics are served by cmd/server, see /metrics, or logged on lambda.
+	// Tracing comes first, so anything logged is within the call's span.
+	measure := Metrics(prometheus.DefaultRegisterer)
+	if integration == false {
+		measure = EMFMetrics(LambdaMetrics)
+	}
+
+	usecase := &Pipeline{
+		Usecase: &Usecase{
+			Repository: repository,
+			Retention:  retention,
+			IDs:        ids,
+		},
+		Interceptors: []Interceptor{
+			Tracing(),
+			Logging(logger),
+			measure,
+			Retry(attempts, retryBackoff),
+		},
+	}
+	return usecase, nil
+}
+
+func newRepository(integration bool) (repository, error) {
+	if os.Getenv("DATASTORE") == DatastoreMemory {
+		return NewMemoryRepository(), nil
+	}
+
 	region := os.Getenv("AWS_REGION")
 	sess, err := session.NewSession(&aws.Config{
 		Region: aws.String(region)},
@@ -34,15 +126,12 @@ func Init(integration bool) (UserService, error) {
 	if integration == false {
 		xray.Configure(xray.Config{LogLevel: "trace"})
 		xray.AWS(ddb.Client)
+		InstrumentDynamoDBEMF(ddb.Client, LambdaMetrics)
+	} else {
+		InstrumentDynamoDB(ddb.Client, prometheus.DefaultRegisterer)
 	}
-
-	logger, _ := zap.NewProduction()
+	TraceDynamoDB(ddb.Client)
 
 	tableName := os.Getenv("TABLE_NAME")
-	repository := NewDynamoDBRepository(ddb, tableName)
-	usecase := &LoggerAdapter{
-		Logger:  logger,
-		Usecase: &Usecase{Repository: repository},
-	}
-	return usecase, nil
+	return NewDynamoDBRepository(ddb, tableName), nil
 }
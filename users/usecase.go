@@ -2,26 +2,39 @@ package users
 
 import (
 	"context"
-	"github.com/google/uuid"
+	"github.com/EwanValentine/serverless-api-example/pkg/validation"
 	"github.com/pkg/errors"
-	"gopkg.in/go-playground/validator.v9"
+	"time"
 )
 
-var (
-	validate *validator.Validate
-)
+// DefaultRetention is how long deleted users are
+// kept around for, when no Retention is given
+const DefaultRetention = time.Hour * 24 * 30
 
 type repository interface {
 	Get(ctx context.Context, id string) (*User, error)
-	GetAll(ctx context.Context) ([]*User, error)
-	Update(ctx context.Context, id string, user *UpdateUser) error
+	GetByEmail(ctx context.Context, email string) (*User, error)
+	GetAll(ctx context.Context, limit int, cursor string) ([]*User, string, error)
+	Update(ctx context.Context, id string, user *UpdateUser) (*User, error)
 	Create(ctx context.Context, user *User) error
-	Delete(ctx context.Context, id string) error
+	Delete(ctx context.Context, id string, version int64, deletedAt, purgeAt time.Time) error
+	Restore(ctx context.Context, id string, version int64, restoredAt time.Time) (*User, error)
+	Purge(ctx context.Context, before time.Time) (int, error)
 }
 
 // Usecase for interacting with users
 type Usecase struct {
 	Repository repository
+
+	// Clock timestamps users, defaults to the system clock
+	Clock Clock
+
+	// IDs generates the IDs of new users, defaults to UUIDs
+	IDs IDGenerator
+
+	// Retention is how long deleted users can be restored
+	// for, before they're purged. Defaults to DefaultRetention.
+	Retention time.Duration
 }
 
 // Get a single user
@@ -33,38 +46,61 @@ func (u *Usecase) Get(ctx context.Context, id string) (*User, error) {
 	return user, nil
 }
 
-// GetAll gets all users
-func (u *Usecase) GetAll(ctx context.Context) ([]*User, error) {
-	users, err := u.Repository.GetAll(ctx)
+// GetByEmail gets the user with the given email
+func (u *Usecase) GetByEmail(ctx context.Context, email string) (*User, error) {
+	user, err := u.Repository.GetByEmail(ctx, email)
 	if err != nil {
-		return nil, errors.Wrap(err, "error fetching all users")
+		return nil, errors.Wrap(err, "error fetching a user by email")
 	}
-	return users, nil
+	return user, nil
 }
 
-// Update a single user
-func (u *Usecase) Update(ctx context.Context, id string, user *UpdateUser) error {
-	validate = validator.New()
-	if err := validate.Struct(user); err != nil {
-		validationErrors := err.(validator.ValidationErrors)
-		return validationErrors
+// GetAll gets a page of users, along with the cursor for the next
+// page, which is empty once there are no more users.
+func (u *Usecase) GetAll(ctx context.Context, limit int, cursor string) ([]*User, string, error) {
+	switch {
+	case limit < 0:
+		return nil, "", ErrInvalidLimit
+	case limit == 0:
+		limit = DefaultPageSize
+	case limit > MaxPageSize:
+		limit = MaxPageSize
 	}
 
-	if err := u.Repository.Update(ctx, id, user); err != nil {
-		return errors.Wrap(err, "error updating user")
+	users, next, err := u.Repository.GetAll(ctx, limit, cursor)
+	if err != nil {
+		return nil, "", errors.Wrap(err, "error fetching all users")
 	}
-	return nil
+	return users, next, nil
+}
+
+// Update a single user, returning it as updated
+func (u *Usecase) Update(ctx context.Context, id string, user *UpdateUser) (*User, error) {
+	if err := validation.Struct(ctx, user); err != nil {
+		return nil, err
+	}
+
+	user.UpdatedAt = u.now()
+	updated, err := u.Repository.Update(ctx, id, user)
+	if err != nil {
+		return nil, errors.Wrap(err, "error updating user")
+	}
+	return updated, nil
 }
 
 // Create a single user
 func (u *Usecase) Create(ctx context.Context, user *User) error {
-	validate = validator.New()
-	if err := validate.Struct(*user); err != nil {
-		validationErrors := err.(validator.ValidationErrors)
-		return validationErrors
+	if err := validation.Struct(ctx, user); err != nil {
+		return err
 	}
 
 	user.ID = u.newID()
+	user.Version = 1
+	user.CreatedAt = u.now()
+	user.UpdatedAt = user.CreatedAt
+
+	// New users are live, whatever the client sent
+	user.DeletedAt = nil
 	if err := u.Repository.Create(ctx, user); err != nil {
 		return errors.Wrap(err, "error creating new user")
 	}
@@ -72,15 +108,53 @@ func (u *Usecase) Create(ctx context.Context, user *User) error {
 	return nil
 }
 
-// Delete a single user
-func (u *Usecase) Delete(ctx context.Context, id string) error {
-	if err := u.Repository.Delete(ctx, id); err != nil {
+// Delete a single user, if it's still at the version given, or any
+// version when it's zero. They can be restored until they're purged.
+func (u *Usecase) Delete(ctx context.Context, id string, version int64) error {
+	now := u.now()
+	if err := u.Repository.Delete(ctx, id, version, now, now.Add(u.retention())); err != nil {
 		return errors.Wrap(err, "error deleting user")
 	}
 	return nil
 }
 
+// Restore a deleted user, if it's still at the version
+// given, or any version when it's zero
+func (u *Usecase) Restore(ctx context.Context, id string, version int64) (*User, error) {
+	user, err := u.Repository.Restore(ctx, id, version, u.now())
+	if err != nil {
+		return nil, errors.Wrap(err, "error restoring user")
+	}
+	return user, nil
+}
+
+// Purge users deleted longer ago than the retention for good,
+// returning how many were purged
+func (u *Usecase) Purge(ctx context.Context) (int, error) {
+	purged, err := u.Repository.Purge(ctx, u.now().Add(-u.retention()))
+	if err != nil {
+		return purged, errors.Wrap(err, "error purging deleted users")
+	}
+	return purged, nil
+}
+
+func (u *Usecase) now() time.Time {
+	if u.Clock == nil {
+		return systemClock{}.Now()
+	}
+	return u.Clock.Now()
+}
+
+func (u *Usecase) retention() time.Duration {
+	if u.Retention == 0 {
+		return DefaultRetention
+	}
+	return u.Retention
+}
+
 func (u *Usecase) newID() string {
-	uid := uuid.New()
-	return uid.String()
+	if u.IDs == nil {
+		return UUIDGenerator{}.NewID()
+	}
+	return u.IDs.NewID()
 }
@@ -2,11 +2,21 @@ package users
 
 import (
 	"context"
+	"github.com/EwanValentine/serverless-api-example/pkg/apperrors"
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
 	"testing"
+	"time"
 )
 
+type fixedClock time.Time
+
+func (c fixedClock) Now() time.Time {
+	return time.Time(c)
+}
+
+var now = time.Date(2019, 9, 1, 12, 0, 0, 0, time.UTC)
+
 func TestCanGetUser(t *testing.T) {
 	expected := &User{Name: "Ewan"}
 	ctrl := gomock.NewController(t)
@@ -15,7 +25,7 @@ func TestCanGetUser(t *testing.T) {
 	repo := NewMockrepository(ctrl)
 	repo.EXPECT().Get(context.Background(), "abc123").Return(expected, nil)
 
-	uc := Usecase{repo}
+	uc := Usecase{Repository: repo}
 
 	user, err := uc.Get(context.Background(), "abc123")
 
@@ -33,14 +43,35 @@ func TestCanGetAllUsers(t *testing.T) {
 	defer ctrl.Finish()
 
 	repo := NewMockrepository(ctrl)
-	repo.EXPECT().GetAll(context.Background()).Return(expected, nil)
+	repo.EXPECT().GetAll(context.Background(), 2, "").Return(expected, "next", nil)
 
-	uc := Usecase{repo}
+	uc := Usecase{Repository: repo}
 
-	users, err := uc.GetAll(context.Background())
+	users, next, err := uc.GetAll(context.Background(), 2, "")
 	assert.NoError(t, err)
 	assert.Len(t, users, 2)
 	assert.Equal(t, expected, users)
+	assert.Equal(t, "next", next)
+}
+
+func TestGetAllUsersPageSize(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	repo := NewMockrepository(ctrl)
+	repo.EXPECT().GetAll(context.Background(), DefaultPageSize, "").Return(nil, "", nil)
+	repo.EXPECT().GetAll(context.Background(), MaxPageSize, "abc").Return(nil, "", nil)
+
+	uc := Usecase{Repository: repo}
+
+	_, _, err := uc.GetAll(context.Background(), 0, "")
+	assert.NoError(t, err)
+
+	_, _, err = uc.GetAll(context.Background(), MaxPageSize+1, "abc")
+	assert.NoError(t, err)
+
+	_, _, err = uc.GetAll(context.Background(), -1, "")
+	assert.Equal(t, ErrInvalidLimit, err)
 }
 
 func TestCanCreateUser(t *testing.T) {
@@ -54,10 +85,35 @@ func TestCanCreateUser(t *testing.T) {
 	repo := NewMockrepository(ctrl)
 	repo.EXPECT().Create(context.Background(), expected).Return(nil)
 
-	uc := Usecase{repo}
+	ids := IDGeneratorFunc(func() string { return "abc123" })
+	uc := Usecase{Repository: repo, Clock: fixedClock(now), IDs: ids}
 	err := uc.Create(context.Background(), expected)
 
 	assert.NoError(t, err)
+	assert.Equal(t, "abc123", expected.ID)
+	assert.Equal(t, int64(1), expected.Version)
+	assert.Equal(t, now, expected.CreatedAt)
+	assert.Equal(t, now, expected.UpdatedAt)
+}
+
+func TestCreatedUsersAreNeverDeleted(t *testing.T) {
+	deletedAt := now.Add(-time.Hour)
+	user := &User{
+		Name:      "testing",
+		Email:     "test@test.com",
+		Age:       30,
+		DeletedAt: &deletedAt,
+	}
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	repo := NewMockrepository(ctrl)
+	repo.EXPECT().Create(context.Background(), user).Return(nil)
+
+	uc := Usecase{Repository: repo, Clock: fixedClock(now)}
+	err := uc.Create(context.Background(), user)
+
+	assert.NoError(t, err)
+	assert.Nil(t, user.DeletedAt)
 }
 
 func TestCanValidateUser(t *testing.T) {
@@ -65,7 +121,7 @@ func TestCanValidateUser(t *testing.T) {
 	defer ctrl.Finish()
 	repo := NewMockrepository(ctrl)
 
-	uc := Usecase{repo}
+	uc := Usecase{Repository: repo}
 
 	users := []*User{
 		&User{},                      // No required fields
@@ -75,23 +131,46 @@ func TestCanValidateUser(t *testing.T) {
 	}
 	for _, val := range users {
 		err := uc.Create(context.Background(), val)
-		assert.Error(t, err)
+		assert.Equal(t, apperrors.Validation, apperrors.CodeOf(err))
 	}
 }
 
 func TestCanUpdateUser(t *testing.T) {
+	name, email, age := "new name", "test@test.com", uint32(20)
 	user := &UpdateUser{
-		Name:  "new name",
-		Email: "test@test.com",
-		Age:   20,
+		Name:  &name,
+		Email: &email,
+		Age:   &age,
 	}
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 	repo := NewMockrepository(ctrl)
-	repo.EXPECT().Update(context.Background(), "abc123", user).Return(nil)
-	uc := Usecase{repo}
-	err := uc.Update(context.Background(), "abc123", user)
+	expected := &User{ID: "abc123", Name: name, Email: email, Age: age, Version: 2}
+	repo.EXPECT().Update(context.Background(), "abc123", user).Return(expected, nil)
+	uc := Usecase{Repository: repo, Clock: fixedClock(now)}
+	updated, err := uc.Update(context.Background(), "abc123", user)
 	assert.NoError(t, err)
+	assert.Equal(t, expected, updated)
+	assert.Equal(t, now, user.UpdatedAt)
+}
+
+func TestCanValidateUpdateUser(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	repo := NewMockrepository(ctrl)
+
+	uc := Usecase{Repository: repo}
+
+	blank, email, age := "", "nope", uint32(200)
+	updates := []*UpdateUser{
+		&UpdateUser{Name: &blank}, // Present but blank name
+		&UpdateUser{Email: &email},
+		&UpdateUser{Age: &age},
+	}
+	for _, val := range updates {
+		_, err := uc.Update(context.Background(), "abc123", val)
+		assert.Equal(t, apperrors.Validation, apperrors.CodeOf(err))
+	}
 }
 
 func TestCanDeleteUser(t *testing.T) {
@@ -101,8 +180,33 @@ func TestCanDeleteUser(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 	repo := NewMockrepository(ctrl)
-	repo.EXPECT().Delete(context.Background(), user.ID).Return(nil)
-	uc := Usecase{repo}
-	err := uc.Delete(context.Background(), user.ID)
+	repo.EXPECT().Delete(context.Background(), user.ID, int64(0), now, now.Add(DefaultRetention)).Return(nil)
+	uc := Usecase{Repository: repo, Clock: fixedClock(now)}
+	err := uc.Delete(context.Background(), user.ID, 0)
 	assert.NoError(t, err)
 }
+
+func TestCanPurgeUsers(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	repo := NewMockrepository(ctrl)
+	repo.EXPECT().Purge(context.Background(), now.Add(-time.Hour)).Return(2, nil)
+	uc := Usecase{Repository: repo, Clock: fixedClock(now), Retention: time.Hour}
+	purged, err := uc.Purge(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 2, purged)
+}
+
+func TestGetUserNotFound(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	repo := NewMockrepository(ctrl)
+	repo.EXPECT().Get(context.Background(), "abc123").Return(nil, ErrNotFound)
+
+	uc := Usecase{Repository: repo}
+
+	user, err := uc.Get(context.Background(), "abc123")
+	assert.Nil(t, user)
+	assert.True(t, IsNotFound(err))
+}